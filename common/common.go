@@ -1,29 +1,31 @@
 package common
 
 import (
+	"net/http"
+
 	"github.com/QunQunLab/ego/error"
 )
 
 const (
-	ErrOK                = 0
-	ErrGeneralUnknown    = 100
-	ErrGeneralForbidden  = 101
-	ErrGeneralBadRequest = 102
+	ErrOK                  = 0
+	ErrGeneralUnknown      = 100
+	ErrGeneralForbidden    = 101
+	ErrGeneralBadRequest   = 102
+	ErrGeneralUnauthorized = 103
 )
 
+// CategoryGeneral the category of the errors defined by ego
+const CategoryGeneral = "general"
+
 // Define standard error response with errcode and errmsg
 var (
-	Success = error.Errorf{Code: ErrOK, Msg: map[string]string{"cn": "成功", "en": "success"}} // 200
+	Success = error.Register(error.Errorf{Code: ErrOK, Key: "common.success", Msg: map[string]string{"cn": "成功", "en": "success"}}, http.StatusOK, CategoryGeneral)
 
-	// 404
-	Unknown = error.Errorf{Code: ErrGeneralUnknown, Msg: map[string]string{"cn": "未知错误:%v", "en": "ERR_UNKNOWN:%v"}}
+	Unknown = error.Register(error.Errorf{Code: ErrGeneralUnknown, Key: "common.unknown", Msg: map[string]string{"cn": "未知错误:%v", "en": "ERR_UNKNOWN:%v"}}, http.StatusInternalServerError, CategoryGeneral)
 
-	// 401
-	Unauthorized = error.Errorf{Code: ErrGeneralUnknown, Msg: map[string]string{"cn": "未登录或登录已过期", "en": "Unauthorized request"}}
+	Unauthorized = error.Register(error.Errorf{Code: ErrGeneralUnauthorized, Key: "common.unauthorized", Msg: map[string]string{"cn": "未登录或登录已过期", "en": "Unauthorized request"}}, http.StatusUnauthorized, CategoryGeneral)
 
-	// 403
-	Forbidden = error.Errorf{Code: ErrGeneralForbidden, Msg: map[string]string{"cn": "禁止访问", "en": "You have no permission to access"}}
+	Forbidden = error.Register(error.Errorf{Code: ErrGeneralForbidden, Key: "common.forbidden", Msg: map[string]string{"cn": "禁止访问", "en": "You have no permission to access"}}, http.StatusForbidden, CategoryGeneral)
 
-	// 400
-	BadRequest = error.Errorf{Code: ErrGeneralBadRequest, Msg: map[string]string{"cn": "错误请求", "en": "You have send an error request"}}
+	BadRequest = error.Register(error.Errorf{Code: ErrGeneralBadRequest, Key: "common.bad_request", Msg: map[string]string{"cn": "错误请求", "en": "You have send an error request"}}, http.StatusBadRequest, CategoryGeneral)
 )
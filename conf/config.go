@@ -2,15 +2,16 @@ package conf
 
 import (
 	"bufio"
+	"encoding"
 	"errors"
 	"fmt"
 	"io"
 	"os"
-	"path"
 	"path/filepath"
 	"reflect"
 	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
 )
 
@@ -22,6 +23,8 @@ const (
 	SectionB = "["
 	SectionE = "]"
 	Include  = "include"
+	// IncludeOptional include the files if exist
+	IncludeOptional = "include?"
 )
 
 //	Section
@@ -30,8 +33,11 @@ const (
 //	commonKey commonVal
 //	commonKey commonVal1,commonVal2
 //
-///	# include other file
+///	# include other file, relative to this file, or the files matching
+//	# a glob, "include?" ignore the missing file
 //	include ../common
+//	include conf.d/*.conf
+//	include? local.conf
 //
 //	[sector]
 //	sectorKey sectorVal1,sectorVal2
@@ -39,6 +45,7 @@ type Section struct {
 	delimit string
 	sector  string
 	val     map[string]string // key val1,val2
+	src     map[string]Source // the keys not from file
 }
 
 // An NoKeyError describes a key that was not found in the section.
@@ -216,6 +223,25 @@ type Config struct {
 	Comment string
 	Split   string
 	Delimit string
+	// Strict report the malformed lines, the unknown sections and the
+	// unknown keys against the registered schemas, and support the quoted values, the inline
+	// comments and the multi-line values, see parseStrict
+	Strict bool
+
+	// the layers over the file, see Resolve
+	defaults  map[string]map[string]string
+	commonSrc map[string]Source
+	environ   []string
+	args      []string
+
+	// the parsed files including the included ones, and the include globs
+	// to find the new files, see Watch
+	files []string
+	globs []string
+	// the file and line of the keys "section:key", see Validate
+	positions map[string]string
+	// the secret keys "section:key", see Interpolate
+	secrets map[string]bool
 }
 
 // New return a new default Config object (Comment = '#', Split = ' ', Delimit = ',')
@@ -231,15 +257,25 @@ func New() *Config {
 	}
 }
 
-// ParseReader parse from io.Reader
+// ParseReader parse from io.Reader, the included files are relative to
+// the directory of c.File
 func (c *Config) ParseReader(reader io.Reader) error {
+	if err := c.parseReader(reader, c.File, nil); err != nil {
+		return err
+	}
+	return c.checkStrict()
+}
+
+// parseReader parse the file from reader, chain is the including files
+func (c *Config) parseReader(reader io.Reader, file string, chain []string) error {
+	if c.Strict {
+		return c.parseStrict(reader, file, chain)
+	}
 	var (
 		line      int
 		r         = bufio.NewReader(reader)
 		sector    *Section
 		sectorKey string
-		key       string
-		val       string
 	)
 	for {
 		// process include
@@ -263,12 +299,12 @@ func (c *Config) ParseReader(reader io.Reader) error {
 		}
 		if strings.HasPrefix(row, SectionB) {
 			if !strings.HasSuffix(row, SectionE) {
-				return fmt.Errorf("no end sector %s at file:%v line:%v", SectionE, c.File, line)
+				return fmt.Errorf("no end sector %s at file:%v line:%v", SectionE, file, line)
 			}
 
 			sectorKey = row[1 : len(row)-1]
 			if _, ok := c.Sector[sectorKey]; ok {
-				return fmt.Errorf("sector key %v already exists at file:%v line:%v", sectorKey, c.File, line)
+				return fmt.Errorf("sector key %v already exists at file:%v line:%v", sectorKey, file, line)
 			} else {
 				sector = &Section{
 					delimit: c.Delimit,
@@ -276,45 +312,46 @@ func (c *Config) ParseReader(reader io.Reader) error {
 					val:     make(map[string]string),
 				}
 				c.Sector[sectorKey] = sector
+				c.setPosition(sectorKey, "", fmt.Sprintf("%v:%v", file, line))
 			}
 			continue
 		}
 
-		// key/val in a row
-		idx := strings.Index(row, c.Split)
-		if idx > 0 {
-			key = strings.TrimSpace(row[:idx])
-			if len(row) > idx {
-				val = strings.TrimSpace(row[idx+1:])
+		// process include
+		if pattern, optional, ok := c.includeRow(row); ok {
+			if pattern == "" {
+				return fmt.Errorf("no file to include at file:%v line:%v", file, line)
 			}
-		} else {
-			//return fmt.Errorf("no split in key row %v at file:%v line:%v", row, c.File, line)
+			if err = c.include(pattern, file, optional, chain); err != nil {
+				return fmt.Errorf("%v, included from %v:%v", err, file, line)
+			}
+			continue
 		}
 
+		// key/val in a row, the malformed row is ignored, see Strict
+		idx := strings.Index(row, c.Split)
+		if idx <= 0 {
+			continue
+		}
+		key := strings.TrimSpace(row[:idx])
+		val := strings.TrimSpace(row[idx+len(c.Split):])
+
 		if sector == nil {
-			// process include
-			if strings.Contains(row, Include) {
-				includes := strings.SplitN(row, " ", 2)
-				fileName := strings.Trim(includes[1], c.Split)
-				abs, _ := filepath.Abs(c.File)
-				file := path.Join(path.Dir(abs), fileName)
-				if err = c.Parse(file); err != nil {
-					return err
-				}
-			}
 			// process common config
 			if _, ok := c.Common[key]; ok {
-				return fmt.Errorf("same common key %v at file:%v line:%v", key, c.File, line)
+				return fmt.Errorf("same common key %v at file:%v line:%v", key, file, line)
 			}
 			c.Common[key] = val
+			c.setPosition("", key, fmt.Sprintf("%v:%v", file, line))
 		} else {
 			if c.Sector[sectorKey].val == nil {
 				c.Sector[sectorKey].val = make(map[string]string)
 			}
 			if _, ok := c.Sector[sectorKey].val[key]; ok {
-				return fmt.Errorf("section %s already has key: %s at file:%v line:%d", sectorKey, key, c.File, line)
+				return fmt.Errorf("section %s already has key: %s at file:%v line:%d", sectorKey, key, file, line)
 			} else {
 				c.Sector[sectorKey].val[key] = val
+				c.setPosition(sectorKey, key, fmt.Sprintf("%v:%v", file, line))
 			}
 		}
 	}
@@ -322,33 +359,70 @@ func (c *Config) ParseReader(reader io.Reader) error {
 	return nil
 }
 
-// Parse parse file
+// Parse parse file, the format is chosen by the extension, .json, .yaml,
+// .yml, .toml or the registered ones, see RegisterDecoder. Others are
+// parsed by ParseReader.
 func (c *Config) Parse(file string) error {
+	c.File = file
+	if err := c.parseFile(file, nil); err != nil {
+		return err
+	}
+	return c.checkStrict()
+}
+
+// parseFile parse the file included by the chain of files
+func (c *Config) parseFile(file string, chain []string) error {
+	abs, err := filepath.Abs(file)
+	if err != nil {
+		return err
+	}
+	for i, f := range chain {
+		if f == abs {
+			return fmt.Errorf("include cycle %v", strings.Join(append(chain[i:], abs), " -> "))
+		}
+	}
+	chain = append(chain, abs)
+
 	f, err := os.Open(file)
 	if err != nil {
 		return err
 	}
 	defer f.Close()
-	c.File = file
-	return c.ParseReader(f)
+	c.files = append(c.files, file)
+	if d := decoderOf(file); d != nil {
+		return c.decode(file, d, f, chain)
+	}
+	return c.parseReader(f, file, chain)
 }
 
 // Reload reload config
 func (c *Config) Reload() (*Config, error) {
+	return c.reparse(c.File)
+}
+
+// reparse parse file to a new config with the settings and the layers of c
+func (c *Config) reparse(file string) (*Config, error) {
 	nc := &Config{
 		Common: make(map[string]string),
 		Sector: make(map[string]*Section),
-		File:   c.File,
+		File:   file,
 
 		// config
 		Comment: c.Comment,
 		Split:   c.Split,
 		Delimit: c.Delimit,
+		Strict:  c.Strict,
+
+		defaults: c.defaults,
 	}
-	err := nc.Parse(c.File)
+	err := nc.Parse(file)
 	if err != nil {
 		return nil, err
 	}
+	nc.Resolve(c.environ, c.args)
+	if err = nc.Interpolate(); err != nil {
+		return nil, err
+	}
 	return nc, nil
 }
 
@@ -441,92 +515,73 @@ func (e *InvalidUnmarshalError) Error() string {
 //   // Note the extra tag "memory" only effect the int (memory size is int).
 //   Field int `flag:"base:myName:memory"`
 //
+//   // Field is "8080" if the key is not found.
+//   Field int `flag:"base:myName" default:"8080"`
+//
+//   // Field appears in flag section "base" as key "at", parsed by the
+//   // layout, it's parsed as RFC 3339 without the layout.
+//   Field time.Time `flag:"base:at:2006-01-02"`
+//
+// The types implementing encoding.TextUnmarshaler such as decimal.Decimal
+// are parsed by UnmarshalText. The pointers are allocated if the key is
+// found or has a default.
+//
+// The struct fields without the flag tag are unmarshaled recursively, the
+// fields tagged with a key only appear in the section of the "section" tag,
+// joined to the outer one by "." like the nested tables of yaml and toml:
+//
+//   type DB struct {
+//   	Host string `flag:"host"`
+//   	// section "db.replica"
+//   	Replica *struct {
+//   		Host string `flag:"host"`
+//   	} `section:"replica"`
+//   }
+//   type Option struct {
+//   	DB DB `section:"db"`
+//   }
+//
+// The nested struct pointers are allocated only if any field is set.
 func (c *Config) Unmarshal(v interface{}, flag string) error {
-	vv := reflect.ValueOf(v)
-	if vv.Kind() != reflect.Ptr || vv.IsNil() {
-		return &InvalidUnmarshalError{reflect.TypeOf(v)}
-	}
-	rv := vv.Elem()
-	rt := rv.Type()
-	n := rv.NumField()
-	// enum every struct field
-	for i := 0; i < n; i++ {
-		vf := rv.Field(i)
-		tf := rt.Field(i)
-		tag := tf.Tag.Get(flag)
-		// if tag empty or "-" ignore
-		if tag == "-" || tag == "" || tag == "omitempty" {
-			continue
-		}
-		tagArr := strings.SplitN(tag, ":", 3)
-		if len(tagArr) < 2 {
-			return errors.New(fmt.Sprintf("error tag: %s, must be section:field:delim(optional)", tag))
-		}
-		section := tagArr[0]
-		key := tagArr[1]
-		s := c.Get(section)
-		if s == nil {
-			// no config section
-			continue
-		}
-		value, ok := s.val[key]
-		if !ok {
-			// no config key
-			continue
-		}
-		err := c.getVal(&vf, &tf, tagArr, value)
-		if err != nil {
-			return err
-		}
-	}
-	return nil
+	return c.UnmarshalSection(v, "", flag)
 }
 
+// UnmarshalSection is like Unmarshal, the fields tagged with a key only
+// such as `flag:"myName"` or `flag:":myName:,"` appear in section.
 func (c *Config) UnmarshalSection(v interface{}, section, flag string) error {
 	vv := reflect.ValueOf(v)
-	if vv.Kind() != reflect.Ptr || vv.IsNil() {
+	if vv.Kind() != reflect.Ptr || vv.IsNil() || vv.Elem().Kind() != reflect.Struct {
 		return &InvalidUnmarshalError{reflect.TypeOf(v)}
 	}
-	rv := vv.Elem()
-	rt := rv.Type()
-	n := rv.NumField()
-	// enum every struct field
-	for i := 0; i < n; i++ {
-		vf := rv.Field(i)
-		tf := rt.Field(i)
-		tag := tf.Tag.Get(flag)
-		// if tag empty or "-" ignore
-		if tag == "-" || tag == "" || tag == "omitempty" {
-			continue
-		}
-		tagArr := strings.SplitN(tag, ":", 3)
-		if len(tagArr) < 2 {
-			return errors.New(fmt.Sprintf("error tag: %s, must be section:field:delim(optional)", tag))
-		}
-		section := tagArr[0]
-		key := tagArr[1]
-		if section != section {
-			continue
-		}
-		s := c.Get(section)
-		if s == nil {
-			// no config section
-			continue
-		}
-		value, ok := s.val[key]
-		if !ok {
-			// no config key
-			continue
+	_, err := c.unmarshal(vv.Elem(), section, flag, nil)
+	return err
+}
+
+func (c *Config) getVal(vf *reflect.Value, tf *reflect.StructField, tagArr []string, value string) error {
+	if vf.Kind() == reflect.Ptr {
+		nv := reflect.New(vf.Type().Elem())
+		ev := nv.Elem()
+		if err := c.getVal(&ev, tf, tagArr, value); err != nil {
+			return err
 		}
-		err := c.getVal(&vf, &tf, tagArr, value)
+		vf.Set(nv)
+		return nil
+	}
+	if vf.Type() == timeType && len(tagArr) == 3 {
+		tm, err := time.Parse(tagArr[2], value)
 		if err != nil {
-			return err
+			return fmt.Errorf("struct field %s: %v", tf.Name, err)
 		}
+		vf.Set(reflect.ValueOf(tm))
+		return nil
+	}
+	if u, ok := vf.Addr().Interface().(encoding.TextUnmarshaler); ok {
+		if err := u.UnmarshalText([]byte(value)); err != nil {
+			return fmt.Errorf("struct field %s: %v", tf.Name, err)
+		}
+		return nil
 	}
-	return nil
-}
 
-func (c *Config) getVal(vf *reflect.Value, tf *reflect.StructField, tagArr []string, value string) error {
 	switch vf.Kind() {
 	case reflect.String:
 		vf.SetString(value)
@@ -638,9 +693,9 @@ func (c *Config) getVal(vf *reflect.Value, tf *reflect.StructField, tagArr []str
 			delim = tagArr[2]
 		}
 		strs := strings.Split(value, delim)
-		sli := reflect.MakeSlice(tf.Type, 0, len(strs))
+		sli := reflect.MakeSlice(vf.Type(), 0, len(strs))
 		for _, str := range strs {
-			vv, err := getValue(tf.Type.Elem().String(), str)
+			vv, err := getValue(vf.Type().Elem().String(), str)
 			if err != nil {
 				return err
 			}
@@ -653,17 +708,17 @@ func (c *Config) getVal(vf *reflect.Value, tf *reflect.StructField, tagArr []str
 			delim = tagArr[2]
 		}
 		strs := strings.Split(value, delim)
-		m := reflect.MakeMap(tf.Type)
+		m := reflect.MakeMap(vf.Type())
 		for _, str := range strs {
 			mapStrs := strings.SplitN(str, "=", 2)
 			if len(mapStrs) < 2 {
 				return errors.New(fmt.Sprintf("error map: %s, must be split by \"=\"", str))
 			}
-			vk, err := getValue(tf.Type.Key().String(), mapStrs[0])
+			vk, err := getValue(vf.Type().Key().String(), mapStrs[0])
 			if err != nil {
 				return err
 			}
-			vv, err := getValue(tf.Type.Elem().String(), mapStrs[1])
+			vv, err := getValue(vf.Type().Elem().String(), mapStrs[1])
 			if err != nil {
 				return err
 			}
@@ -802,26 +857,40 @@ func parseMemory(v string) (int, error) {
 	return int(b) * unit, nil
 }
 
-var gconf = &Config{}
+// gconf the global config, it's swapped by Init and Reload
+var gconf atomic.Value
+
+func init() {
+	gconf.Store(&Config{})
+}
+
+// global return the global config
+func global() *Config {
+	return gconf.Load().(*Config)
+}
 
+// Init parse the config file, then the environment variables and the
+// command line flags override, see Config.Resolve, and the references
+// are expanded, see Config.Interpolate. It panics if failed, see Load.
 func Init(file string) {
-	gconf = New()
-	err := gconf.Parse(file)
+	g := global()
+	c, err := Load(file, LoadOptions{Defaults: g.defaults, Strict: atomic.LoadInt32(&strictMode) == 1})
 	if err != nil {
 		panic(err)
 	}
+	gconf.Store(c)
 }
 
 func Get(section string) *Section {
-	return gconf.Get(section)
+	return global().Get(section)
 }
 
 func GetKey(key string) string {
-	return gconf.GetKey(key)
+	return global().GetKey(key)
 }
 
 func GetKeys(key string) []string {
-	return gconf.GetKeys(key)
+	return global().GetKeys(key)
 }
 
 func Unmarshal(v interface{}, flag ...string) error {
@@ -829,7 +898,7 @@ func Unmarshal(v interface{}, flag ...string) error {
 	if len(flag) > 0 {
 		f = flag[0]
 	}
-	return gconf.Unmarshal(v, f)
+	return global().Unmarshal(v, f)
 }
 
 func UnmarshalSection(v interface{}, section string, flag ...string) error {
@@ -837,5 +906,5 @@ func UnmarshalSection(v interface{}, section string, flag ...string) error {
 	if len(flag) > 0 {
 		f = flag[0]
 	}
-	return gconf.UnmarshalSection(v, section, f)
+	return global().UnmarshalSection(v, section, f)
 }
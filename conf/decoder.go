@@ -0,0 +1,217 @@
+package conf
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Decoder decode a config file into a tree of map[string]interface{},
+// []interface{} and scalars, see RegisterDecoder
+type Decoder func(data []byte) (map[string]interface{}, error)
+
+var (
+	decodersMu sync.RWMutex
+	decoders   = map[string]Decoder{
+		".json": decodeJSON,
+		".yaml": DecodeYAML,
+		".yml":  DecodeYAML,
+		".toml": decodeTOML,
+	}
+)
+
+// RegisterDecoder register the decoder of the file extension such as
+// ".hcl". Files of other extensions are parsed by ParseReader.
+//
+// The decoded tree is mapped onto the config: the scalars of the root are
+// the common config, the tables are the sections, and the nested tables
+// are the sections named "section.sub". The arrays of scalars are joined
+// by the delimiter, the arrays of tables are the sections "section.0",
+// "section.1"... The "include" and "include?" keys of the root are a file
+// or a list of files included like the include of the conf format.
+func RegisterDecoder(ext string, d Decoder) {
+	decodersMu.Lock()
+	defer decodersMu.Unlock()
+	decoders[strings.ToLower(ext)] = d
+}
+
+func decoderOf(file string) Decoder {
+	decodersMu.RLock()
+	defer decodersMu.RUnlock()
+	return decoders[strings.ToLower(path.Ext(file))]
+}
+
+// decode parse the file by the decoder, chain is the including files
+func (c *Config) decode(file string, d Decoder, r io.Reader, chain []string) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	tree, err := d(data)
+	if err != nil {
+		return fmt.Errorf("%v at file:%v", err, file)
+	}
+	return c.load(file, tree, chain)
+}
+
+// load map the decoded tree of file onto the config
+func (c *Config) load(file string, tree map[string]interface{}, chain []string) error {
+	for _, directive := range []string{Include, IncludeOptional} {
+		inc, ok := tree[directive]
+		if !ok {
+			continue
+		}
+		var includes []interface{}
+		switch v := inc.(type) {
+		case string:
+			includes = []interface{}{v}
+		case []interface{}:
+			includes = v
+		default:
+			return fmt.Errorf("invalid %v %v at file:%v", directive, inc, file)
+		}
+		for _, i := range includes {
+			pattern, ok := i.(string)
+			if !ok || pattern == "" {
+				return fmt.Errorf("invalid %v %v at file:%v", directive, i, file)
+			}
+			if err := c.include(pattern, file, directive == IncludeOptional, chain); err != nil {
+				return fmt.Errorf("%v, included from %v", err, file)
+			}
+		}
+	}
+
+	for _, key := range sortedKeys(tree) {
+		if key == Include || key == IncludeOptional {
+			continue
+		}
+		if table, ok := tree[key].(map[string]interface{}); ok {
+			if err := c.loadSection(file, key, table); err != nil {
+				return err
+			}
+		} else if tables, ok := tableArray(tree[key]); ok {
+			for i, t := range tables {
+				if err := c.loadSection(file, key+"."+strconv.Itoa(i), t); err != nil {
+					return err
+				}
+			}
+		} else {
+			val, err := c.scalar(tree[key])
+			if err != nil {
+				return fmt.Errorf("common key %v %v at file:%v", key, err, file)
+			}
+			if _, ok := c.Common[key]; ok {
+				return fmt.Errorf("same common key %v at file:%v", key, file)
+			}
+			c.Common[key] = val
+			c.setPosition("", key, file)
+		}
+	}
+	return nil
+}
+
+func (c *Config) loadSection(file, name string, table map[string]interface{}) error {
+	if _, ok := c.Sector[name]; ok {
+		return fmt.Errorf("sector key %v already exists at file:%v", name, file)
+	}
+	s := c.section(name)
+	c.setPosition(name, "", file)
+	for _, key := range sortedKeys(table) {
+		if sub, ok := table[key].(map[string]interface{}); ok {
+			if err := c.loadSection(file, name+"."+key, sub); err != nil {
+				return err
+			}
+		} else if tables, ok := tableArray(table[key]); ok {
+			for i, t := range tables {
+				if err := c.loadSection(file, name+"."+key+"."+strconv.Itoa(i), t); err != nil {
+					return err
+				}
+			}
+		} else {
+			val, err := c.scalar(table[key])
+			if err != nil {
+				return fmt.Errorf("section %s key %s %v at file:%v", name, key, err, file)
+			}
+			s.val[key] = val
+			c.setPosition(name, key, file)
+		}
+	}
+	return nil
+}
+
+// scalar return the string of a scalar or an array of scalars
+func (c *Config) scalar(v interface{}) (string, error) {
+	switch val := v.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return val, nil
+	case bool:
+		return strconv.FormatBool(val), nil
+	case json.Number:
+		return val.String(), nil
+	case float64:
+		return strconv.FormatFloat(val, 'f', -1, 64), nil
+	case int64:
+		return strconv.FormatInt(val, 10), nil
+	case int:
+		return strconv.Itoa(val), nil
+	case []interface{}:
+		vals := make([]string, len(val))
+		for i, e := range val {
+			switch e.(type) {
+			case []interface{}, map[string]interface{}:
+				return "", fmt.Errorf("nested array is not supported")
+			}
+			s, err := c.scalar(e)
+			if err != nil {
+				return "", err
+			}
+			vals[i] = s
+		}
+		return strings.Join(vals, c.Delimit), nil
+	}
+	return "", fmt.Errorf("unsupported value %v", v)
+}
+
+// tableArray return the tables if v is an array of tables
+func tableArray(v interface{}) ([]map[string]interface{}, bool) {
+	arr, ok := v.([]interface{})
+	if !ok || len(arr) == 0 {
+		return nil, false
+	}
+	tables := make([]map[string]interface{}, len(arr))
+	for i, e := range arr {
+		t, ok := e.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		tables[i] = t
+	}
+	return tables, true
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func decodeJSON(data []byte) (map[string]interface{}, error) {
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.UseNumber()
+	tree := map[string]interface{}{}
+	if err := d.Decode(&tree); err != nil {
+		return nil, err
+	}
+	return tree, nil
+}
@@ -0,0 +1,263 @@
+package conf
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var formats = map[string]string{
+	"app.conf": `
+app_name = ego
+tags = a,b
+[http_conf]
+port = 8080
+debug = true
+timeout = 30s
+[http_conf.tls]
+cert = /etc/cert.pem
+[db.0]
+dsn = root@/ego
+[db.1]
+dsn = root@/log
+`,
+	"app.json": `{
+	"app_name": "ego",
+	"tags": ["a", "b"],
+	"http_conf": {"port": 8080, "debug": true, "timeout": "30s", "tls": {"cert": "/etc/cert.pem"}},
+	"db": [{"dsn": "root@/ego"}, {"dsn": "root@/log"}]
+}`,
+	"app.yaml": `
+# app config
+app_name: ego
+tags: [a, b]
+http_conf:
+  port: 8080      # the port
+  debug: true
+  timeout: "30s"
+  tls:
+    cert: /etc/cert.pem
+db:
+- dsn: root@/ego
+- dsn: 'root@/log'
+`,
+	"app.toml": `
+# app config
+app_name = "ego"
+tags = [
+	"a",
+	"b", # trailing comma
+]
+
+[http_conf]
+port = 8_080 # the port
+debug = true
+timeout = "30s"
+tls = { cert = '/etc/cert.pem' }
+
+[[db]]
+dsn = "root@/ego"
+
+[[db]]
+dsn = "root@/log"
+`,
+}
+
+func writeFiles(t *testing.T, files map[string]string) string {
+	dir := t.TempDir()
+	for name, data := range files {
+		if err := os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestDecoders(t *testing.T) {
+	dir := writeFiles(t, formats)
+	want := New()
+	if err := want.Parse(filepath.Join(dir, "app.conf")); err != nil {
+		t.Fatal(err)
+	}
+
+	for name := range formats {
+		c := New()
+		if err := c.Parse(filepath.Join(dir, name)); err != nil {
+			t.Fatalf("%v: %v", name, err)
+		}
+		if !reflect.DeepEqual(c.Common, want.Common) {
+			t.Errorf("%v: common = %v, want %v", name, c.Common, want.Common)
+		}
+		if len(c.Sector) != len(want.Sector) {
+			t.Errorf("%v: sections = %v, want %v", name, len(c.Sector), len(want.Sector))
+		}
+		for s, ws := range want.Sector {
+			if cs := c.Get(s); cs == nil || !reflect.DeepEqual(cs.val, ws.val) {
+				t.Errorf("%v: section %v = %v, want %v", name, s, cs, ws.val)
+			}
+		}
+
+		port, err := c.Get("http_conf").Int("port")
+		if err != nil || port != 8080 {
+			t.Errorf("%v: port = %v %v", name, port, err)
+		}
+		var v struct {
+			Debug   bool   `json:"http_conf:debug"`
+			Timeout int64  `json:"http_conf:timeout:time"`
+			DSN     string `json:"db.1:dsn"`
+		}
+		if err = c.Unmarshal(&v, "json"); err != nil {
+			t.Fatal(err)
+		}
+		if !v.Debug || v.Timeout != int64(30e9) || v.DSN != "root@/log" {
+			t.Errorf("%v: unmarshal = %+v", name, v)
+		}
+		if tags := c.GetKeys("tags"); !reflect.DeepEqual(tags, []string{"a", "b"}) {
+			t.Errorf("%v: tags = %v", name, tags)
+		}
+	}
+}
+
+func TestDecoderInclude(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"main.yaml":     "include: [db.toml, conf/log.json]\napp_name: ego\n",
+		"db.toml":       "[db]\ndsn = 'root@/ego'\n",
+		"conf/log.json": `{"log": {"level": "info"}}`,
+	})
+
+	c := New()
+	if err := c.Parse(filepath.Join(dir, "main.yaml")); err != nil {
+		t.Fatal(err)
+	}
+	dsn, _ := c.Get("db").String("dsn")
+	level, _ := c.Get("log").String("level")
+	if dsn != "root@/ego" || level != "info" || c.GetKey("app_name") != "ego" {
+		t.Errorf("dsn = %v, level = %v, common = %v", dsn, level, c.Common)
+	}
+	if len(c.files) != 3 {
+		t.Errorf("files = %v", c.files)
+	}
+}
+
+func TestDecodeYAML(t *testing.T) {
+	tree, err := DecodeYAML([]byte(`
+str: "a\tb # not comment"
+single: 'it''s'
+empty:
+null_val: ~
+list:
+  - 1
+  - [2, 3]
+flow: {a: 1, b: [x, y]}
+literal: |
+  line1
+   line2
+
+folded: >-
+  a
+  b
+
+  c
+nested:
+  - name: x
+    tags:
+    - t1
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{
+		"str":      "a\tb # not comment",
+		"single":   "it's",
+		"empty":    nil,
+		"null_val": nil,
+		"list":     []interface{}{"1", []interface{}{"2", "3"}},
+		"flow":     map[string]interface{}{"a": "1", "b": []interface{}{"x", "y"}},
+		"literal":  "line1\n line2\n",
+		"folded":   "a b\nc",
+		"nested": []interface{}{
+			map[string]interface{}{"name": "x", "tags": []interface{}{"t1"}},
+		},
+	}
+	if !reflect.DeepEqual(tree, want) {
+		t.Errorf("tree = %#v", tree)
+	}
+
+	for _, bad := range []string{
+		"a: 1\na: 2",
+		"a:\n\tb: 1",
+		"a: [1, 2",
+		"- a",
+		"a: 1\n  b: 2",
+		"a: *ref",
+	} {
+		if _, err := DecodeYAML([]byte(bad)); err == nil || !strings.HasPrefix(err.Error(), "line ") {
+			t.Errorf("decode %q err = %v", bad, err)
+		}
+	}
+}
+
+func TestDecodeTOML(t *testing.T) {
+	tree, err := decodeTOML([]byte(`
+"quoted key" = 'C:\path'
+a.b.c = 0x1f
+float = 1e3
+date = 1979-05-27 07:32:00
+multi = """
+line1 \
+  line2"""
+raw = '''
+a\n'''
+
+[t]
+x = -12
+
+[t.sub]
+y = "\u00e9"
+
+[[arr.item]]
+n = 1
+
+[[arr.item]]
+n = 2
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{
+		"quoted key": `C:\path`,
+		"a":          map[string]interface{}{"b": map[string]interface{}{"c": "31"}},
+		"float":      "1e3",
+		"date":       "1979-05-27 07:32:00",
+		"multi":      "line1 line2",
+		"raw":        `a\n`,
+		"t":          map[string]interface{}{"x": "-12", "sub": map[string]interface{}{"y": "é"}},
+		"arr": map[string]interface{}{"item": []interface{}{
+			map[string]interface{}{"n": "1"},
+			map[string]interface{}{"n": "2"},
+		}},
+	}
+	if !reflect.DeepEqual(tree, want) {
+		t.Errorf("tree = %#v", tree)
+	}
+
+	for _, bad := range []string{
+		"a = 1\na = 2",
+		"[t]\n[t]",
+		"a = 1\n[[a]]",
+		"a = 012",
+		"a = \"unterminated",
+		"a = [1, 2",
+		"a = 1 b = 2",
+		"a = yes",
+	} {
+		if _, err := decodeTOML([]byte(bad)); err == nil || !strings.HasPrefix(err.Error(), "line ") {
+			t.Errorf("decode %q err = %v", bad, err)
+		}
+	}
+}
@@ -0,0 +1,205 @@
+package conf
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+)
+
+// the formats of Dump
+const (
+	FormatConf = "conf"
+	FormatJSON = "json"
+	FormatYAML = "yaml"
+)
+
+// Value a key of the merged config and where it's from
+type Value struct {
+	Section string `json:"section"`
+	Key     string `json:"key"`
+	// the value, Mask if it's secret
+	Value  string `json:"value"`
+	Source string `json:"source"`
+	// the file and line if it's from a file
+	Pos string `json:"pos,omitempty"`
+}
+
+// Values return the keys of the merged config, the common keys first, then
+// the sections sorted by name
+func (c *Config) Values() []Value {
+	var vals []Value
+	add := func(section string, keys []string) {
+		for _, key := range keys {
+			v := Value{
+				Section: section,
+				Key:     key,
+				Value:   c.Masked(section, key),
+				Source:  c.Source(section, key).String(),
+			}
+			if c.Source(section, key) == SourceFile {
+				v.Pos = c.positions[section+":"+key]
+			}
+			vals = append(vals, v)
+		}
+	}
+	add("", sortedStringKeys(c.Common))
+	for _, name := range c.sectionNames() {
+		add(name, sortedStringKeys(c.Sector[name].val))
+	}
+	return vals
+}
+
+func (c *Config) sectionNames() []string {
+	names := make([]string, 0, len(c.Sector))
+	for name := range c.Sector {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// DumpOptions the options of Dump
+type DumpOptions struct {
+	// print where each value is from, as the comment above the key in the
+	// conf and yaml formats, or the array of Value in the json format
+	Sources bool
+}
+
+// Dump write the merged config in the format, conf, json or yaml. It's
+// meant to be read, not parsed back: the secret values are masked, and a
+// literal ${...} written as $${...} in the file is dumped unescaped, so
+// it would be interpolated again.
+func (c *Config) Dump(w io.Writer, format string, op ...DumpOptions) error {
+	var opt DumpOptions
+	if len(op) > 0 {
+		opt = op[0]
+	}
+	var buf bytes.Buffer
+	switch format {
+	case FormatConf:
+		c.dumpConf(&buf, opt)
+	case FormatJSON:
+		if err := c.dumpJSON(&buf, opt); err != nil {
+			return err
+		}
+	case FormatYAML, "yml":
+		c.dumpYAML(&buf, opt)
+	default:
+		return fmt.Errorf("unknown config format %v", format)
+	}
+	_, err := buf.WriteTo(w)
+	return err
+}
+
+// WriteTo write the merged config in the conf format. It implements
+// io.WriterTo, so the format can't be a parameter, use Dump for json or
+// yaml.
+func (c *Config) WriteTo(w io.Writer) (int64, error) {
+	var buf bytes.Buffer
+	c.dumpConf(&buf, DumpOptions{})
+	return buf.WriteTo(w)
+}
+
+func (c *Config) dumpConf(buf *bytes.Buffer, opt DumpOptions) {
+	section := ""
+	for _, v := range c.Values() {
+		if v.Section != section {
+			section = v.Section
+			if buf.Len() > 0 {
+				buf.WriteString("\n")
+			}
+			fmt.Fprintf(buf, "%s%s%s\n", SectionB, section, SectionE)
+		}
+		if opt.Sources {
+			fmt.Fprintf(buf, "%s %s\n", c.Comment, v.where())
+		}
+		fmt.Fprintf(buf, "%s %s %s\n", v.Key, c.Split, v.Value)
+	}
+	for _, name := range c.sectionNames() {
+		if len(c.Sector[name].val) == 0 {
+			fmt.Fprintf(buf, "\n%s%s%s\n", SectionB, name, SectionE)
+		}
+	}
+}
+
+func (c *Config) dumpJSON(buf *bytes.Buffer, opt DumpOptions) error {
+	var out interface{}
+	if opt.Sources {
+		out = c.Values()
+	} else {
+		tree := map[string]interface{}{}
+		for _, v := range c.Values() {
+			if v.Section == "" {
+				tree[v.Key] = v.Value
+				continue
+			}
+			t, ok := tree[v.Section].(map[string]string)
+			if !ok {
+				if _, exist := tree[v.Section]; exist {
+					return fmt.Errorf("section %v conflicts with the common key", v.Section)
+				}
+				t = map[string]string{}
+				tree[v.Section] = t
+			}
+			t[v.Key] = v.Value
+		}
+		// the empty sections
+		for name := range c.Sector {
+			if _, ok := tree[name]; !ok {
+				tree[name] = map[string]string{}
+			}
+		}
+		out = tree
+	}
+	enc := json.NewEncoder(buf)
+	enc.SetIndent("", "  ")
+	return enc.Encode(out)
+}
+
+func (c *Config) dumpYAML(buf *bytes.Buffer, opt DumpOptions) {
+	var (
+		section string
+		indent  string
+	)
+	for _, v := range c.Values() {
+		if v.Section != section {
+			section, indent = v.Section, "  "
+			fmt.Fprintf(buf, "%s:\n", yamlKey(section))
+		}
+		if opt.Sources {
+			fmt.Fprintf(buf, "%s# %s\n", indent, v.where())
+		}
+		fmt.Fprintf(buf, "%s%s: %s\n", indent, yamlKey(v.Key), strconv.Quote(v.Value))
+	}
+	for _, name := range c.sectionNames() {
+		if len(c.Sector[name].val) == 0 {
+			fmt.Fprintf(buf, "%s: {}\n", yamlKey(name))
+		}
+	}
+}
+
+var plainYAMLKey = regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9_.\-]*$`)
+
+func yamlKey(key string) string {
+	if plainYAMLKey.MatchString(key) {
+		return key
+	}
+	return strconv.Quote(key)
+}
+
+// where return the position or the source of the value
+func (v Value) where() string {
+	if v.Pos != "" {
+		return v.Pos
+	}
+	return v.Source
+}
+
+// Dump write the global config, see Config.Dump
+func Dump(w io.Writer, format string, op ...DumpOptions) error {
+	return global().Dump(w, format, op...)
+}
@@ -0,0 +1,89 @@
+package conf
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDump(t *testing.T) {
+	c := New()
+	err := c.ParseReader(strings.NewReader(`
+app_name = ego
+[http_conf]
+port = 8080
+cors = a.com,b.com
+[db.replica]
+dsn = root:${db.password}@/ego
+[db]
+password = secret://env/EGO_TEST_DUMP
+[empty]
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Resolve([]string{"EGO_HTTP_CONF_PORT=9090", "EGO_TEST_DUMP=s3cret"}, nil)
+	if err = c.Interpolate(); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if _, err = c.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	want := `app_name = ego
+
+[db]
+password = ******
+
+[db.replica]
+dsn = ******
+
+[http_conf]
+cors = a.com,b.com
+port = 9090
+
+[empty]
+`
+	if buf.String() != want {
+		t.Errorf("conf = %s", buf.String())
+	}
+
+	dir := t.TempDir()
+	for _, format := range []string{FormatConf, FormatJSON, FormatYAML} {
+		buf.Reset()
+		if err = c.Dump(&buf, format); err != nil {
+			t.Fatal(err)
+		}
+		if strings.Contains(buf.String(), "s3cret") {
+			t.Errorf("%v: secret is dumped: %s", format, buf.String())
+		}
+		file := filepath.Join(dir, "app."+format)
+		if err = os.WriteFile(file, buf.Bytes(), 0644); err != nil {
+			t.Fatal(err)
+		}
+		nc := New()
+		if err = nc.Parse(file); err != nil {
+			t.Fatalf("%v: %v\n%s", format, err, buf.String())
+		}
+		if len(nc.Sector) != len(c.Sector) || !reflect.DeepEqual(nc.Common, c.Common) {
+			t.Errorf("%v: parsed = %v %v", format, nc.Common, nc.Sector)
+		}
+		for _, ch := range Diff(c, nc) {
+			if !c.IsSecret(ch.Section, ch.Key) {
+				t.Errorf("%v: change %v", format, ch)
+			}
+		}
+	}
+
+	buf.Reset()
+	if err = c.Dump(&buf, FormatYAML, DumpOptions{Sources: true}); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "http_conf:\n  # :5\n  cors: \"a.com,b.com\"\n  # env\n  port: \"9090\"\n") {
+		t.Errorf("yaml = %s", buf.String())
+	}
+}
@@ -0,0 +1,55 @@
+package conf
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// includeRow return the file pattern of the include directive row such as
+// "include common.conf" or "include? local.conf"
+func (c *Config) includeRow(row string) (pattern string, optional bool, ok bool) {
+	for _, directive := range []string{IncludeOptional, Include} {
+		if !strings.HasPrefix(row, directive) {
+			continue
+		}
+		rest := row[len(directive):]
+		if rest != "" && rest[0] != ' ' && rest[0] != '\t' && !strings.HasPrefix(rest, c.Split) {
+			// a key such as include_dir
+			return "", false, false
+		}
+		rest = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(rest), c.Split))
+		return strings.Trim(rest, `"'`), directive == IncludeOptional, true
+	}
+	return "", false, false
+}
+
+// include parse the files of pattern relative to the including file from.
+// A glob matching no file is ignored, so is a missing optional file, both
+// are watched to be reloaded once a file is created.
+func (c *Config) include(pattern, from string, optional bool, chain []string) error {
+	if !filepath.IsAbs(pattern) && from != "" {
+		pattern = filepath.Join(filepath.Dir(from), pattern)
+	}
+	if strings.ContainsAny(pattern, "*?[") {
+		files, err := filepath.Glob(pattern)
+		if err != nil {
+			return fmt.Errorf("invalid include pattern %v: %v", pattern, err)
+		}
+		c.globs = append(c.globs, pattern)
+		for _, file := range files {
+			if err = c.parseFile(file, chain); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if optional {
+		if _, err := os.Stat(pattern); os.IsNotExist(err) {
+			c.files = append(c.files, pattern)
+			return nil
+		}
+	}
+	return c.parseFile(pattern, chain)
+}
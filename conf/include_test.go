@@ -0,0 +1,81 @@
+package conf
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestInclude(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"app.conf": `include conf.d/*.conf
+include? local.conf
+include? missing.conf
+include_dir = /etc
+[app]
+include = sub/db.conf
+name = ego
+`,
+		"conf.d/a.conf":   "a = 1\n",
+		"conf.d/b.conf":   "include ../sub/log.yaml\nb = 2\n",
+		"local.conf":      "local = 1\n",
+		"sub/db.conf":     "[db]\ndsn = root@/ego\n",
+		"sub/log.yaml":    "log:\n  level: info\n",
+		"conf.d/skip.txt": "skip = 1\n",
+	})
+
+	c := New()
+	file := filepath.Join(dir, "app.conf")
+	if err := c.Parse(file); err != nil {
+		t.Fatal(err)
+	}
+	if c.File != file {
+		t.Errorf("file = %v", c.File)
+	}
+	for _, key := range []string{"a", "b", "local", "include_dir"} {
+		if c.GetKey(key) == "" {
+			t.Errorf("common %v is missing: %v", key, c.Common)
+		}
+	}
+	if _, ok := c.Common["skip"]; ok {
+		t.Error("glob matched conf.d/skip.txt")
+	}
+	name, _ := c.Get("app").String("name")
+	dsn, _ := c.Get("db").String("dsn")
+	level, _ := c.Get("log").String("level")
+	if name != "ego" || dsn != "root@/ego" || level != "info" {
+		t.Errorf("name = %v, dsn = %v, level = %v", name, dsn, level)
+	}
+	if pos := c.position("", "b"); pos != filepath.Join(dir, "conf.d/b.conf")+":2" {
+		t.Errorf("position = %v", pos)
+	}
+	// the missing optional file is watched
+	if len(c.files) != 7 || c.files[len(c.files)-2] != filepath.Join(dir, "missing.conf") {
+		t.Errorf("files = %v", c.files)
+	}
+}
+
+func TestIncludeError(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"cycle.conf":   "include cycle_b.conf\n",
+		"cycle_b.conf": "include cycle.conf\n",
+		"bare.conf":    "include\n",
+		"nested.conf":  "x = 1\ninclude bad.conf\n",
+		"bad.conf":     "[s]\n[s]\n",
+		"missing.yaml": "include: none.json\n",
+	})
+	cases := map[string]string{
+		"cycle.conf": "include cycle " + filepath.Join(dir, "cycle.conf") + " -> " +
+			filepath.Join(dir, "cycle_b.conf") + " -> " + filepath.Join(dir, "cycle.conf"),
+		"bare.conf": "no file to include at file:" + filepath.Join(dir, "bare.conf") + " line:1",
+		"nested.conf": "sector key s already exists at file:" + filepath.Join(dir, "bad.conf") +
+			" line:2, included from " + filepath.Join(dir, "nested.conf") + ":2",
+		"missing.yaml": "none.json: no such file or directory, included from " + filepath.Join(dir, "missing.yaml"),
+	}
+	for name, want := range cases {
+		err := New().Parse(filepath.Join(dir, name))
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%v err = %v, want %v", name, err, want)
+		}
+	}
+}
@@ -0,0 +1,204 @@
+package conf
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Interpolate expand the references in the values, it's called by Init
+// and Reload after Resolve:
+//
+//	${section.key}        the value of the key in section, split at the
+//	                      last ".", such as ${db.replica.host}
+//	${key}                the value of the common key
+//	${ENV:VAR}            the environment variable of Resolve, it must be set
+//	${ENV:VAR:-default}   the environment variable, or default if it's
+//	                      unset or empty
+//	$${...}               the literal ${...}
+//
+// Then the values like secret://<provider>/<ref> are resolved by the
+// SecretProvider and masked by Masked and Diff. A value interpolated with
+// a secret is a secret too. The reference cycles are errors.
+func (c *Config) Interpolate() error {
+	it := &interpolator{c: c, state: map[string]int{}}
+	for _, key := range sortedStringKeys(c.Common) {
+		if _, err := it.resolve("", key); err != nil {
+			return err
+		}
+	}
+	sections := make([]string, 0, len(c.Sector))
+	for name := range c.Sector {
+		sections = append(sections, name)
+	}
+	sort.Strings(sections)
+	for _, name := range sections {
+		for _, key := range sortedStringKeys(c.Sector[name].val) {
+			if _, err := it.resolve(name, key); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+const (
+	visiting = iota + 1
+	resolved
+)
+
+type interpolator struct {
+	c     *Config
+	state map[string]int
+	// the keys being resolved, to report the cycle
+	stack []string
+}
+
+func keyName(section, key string) string {
+	if section == "" {
+		return key
+	}
+	return section + ":" + key
+}
+
+// resolve expand the value of the key in section and set it back
+func (it *interpolator) resolve(section, key string) (string, error) {
+	c := it.c
+	id := section + ":" + key
+	switch it.state[id] {
+	case resolved:
+		return c.lookup(section, key), nil
+	case visiting:
+		var cycle []string
+		for i, name := range it.stack {
+			if name == keyName(section, key) {
+				cycle = append(cycle, it.stack[i:]...)
+				break
+			}
+		}
+		cycle = append(cycle, keyName(section, key))
+		return "", fmt.Errorf("config reference cycle %s", strings.Join(cycle, " -> "))
+	}
+	it.state[id] = visiting
+	it.stack = append(it.stack, keyName(section, key))
+
+	val, secret, err := it.expand(c.lookup(section, key))
+	if err == nil && strings.HasPrefix(val, SecretPrefix) {
+		val, err = c.resolveSecret(val)
+		secret = true
+	}
+	if err != nil {
+		if pos := c.position(section, key); pos != "" {
+			return "", fmt.Errorf("%s (%s) %v", keyName(section, key), pos, err)
+		}
+		return "", fmt.Errorf("%s %v", keyName(section, key), err)
+	}
+
+	if section == "" {
+		c.Common[key] = val
+	} else {
+		c.Sector[section].val[key] = val
+	}
+	if secret {
+		if c.secrets == nil {
+			c.secrets = make(map[string]bool)
+		}
+		c.secrets[id] = true
+	}
+	it.stack = it.stack[:len(it.stack)-1]
+	it.state[id] = resolved
+	return val, nil
+}
+
+// expand expand the references in s, it returns whether any referenced
+// value is secret
+func (it *interpolator) expand(s string) (string, bool, error) {
+	if !strings.Contains(s, "${") {
+		return s, false, nil
+	}
+	var (
+		sb     strings.Builder
+		secret bool
+	)
+	for {
+		idx := strings.Index(s, "${")
+		if idx < 0 {
+			sb.WriteString(s)
+			return sb.String(), secret, nil
+		}
+		if idx > 0 && s[idx-1] == '$' {
+			// $${...} is the literal ${...}
+			sb.WriteString(s[:idx])
+			sb.WriteString("{")
+			s = s[idx+2:]
+			continue
+		}
+		end := strings.Index(s[idx:], "}")
+		if end < 0 {
+			return "", false, fmt.Errorf("unterminated reference %s", s[idx:])
+		}
+		sb.WriteString(s[:idx])
+		ref := s[idx+2 : idx+end]
+		s = s[idx+end+1:]
+
+		if strings.HasPrefix(ref, "ENV:") {
+			name, def := ref[4:], ""
+			hasDef := false
+			if i := strings.Index(name, ":-"); i >= 0 {
+				name, def, hasDef = name[:i], name[i+2:], true
+			}
+			val, ok := it.c.lookupEnv(name)
+			switch {
+			case hasDef && val == "":
+				val = def
+			case !ok:
+				return "", false, fmt.Errorf("environment variable %s of ${%s} is not set", name, ref)
+			}
+			sb.WriteString(val)
+			continue
+		}
+
+		section, key := "", ref
+		if i := strings.LastIndex(ref, "."); i >= 0 {
+			section, key = ref[:i], ref[i+1:]
+		}
+		if !it.c.has(section, key) {
+			return "", false, fmt.Errorf("undefined reference ${%s}", ref)
+		}
+		val, err := it.resolve(section, key)
+		if err != nil {
+			return "", false, err
+		}
+		secret = secret || it.c.IsSecret(section, key)
+		sb.WriteString(val)
+	}
+}
+
+// lookup return the value of the key in section, the common key if
+// section is ""
+func (c *Config) lookup(section, key string) string {
+	if section == "" {
+		return c.Common[key]
+	}
+	val, _ := c.value(section, key)
+	return val
+}
+
+// has return whether the key in section exists
+func (c *Config) has(section, key string) bool {
+	if section == "" {
+		_, ok := c.Common[key]
+		return ok
+	}
+	_, ok := c.value(section, key)
+	return ok
+}
+
+func sortedStringKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,99 @@
+package conf
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestInterpolate(t *testing.T) {
+	dir := t.TempDir()
+	secretFile := filepath.Join(dir, "db_password")
+	if err := os.WriteFile(secretFile, []byte("s3cret\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("EGO_TEST_HOST", "db.local")
+	t.Setenv("EGO_TEST_EMPTY", "")
+	RegisterSecretProvider("test", SecretFunc(func(ref string) (string, error) {
+		return "token-" + ref, nil
+	}))
+
+	c := New()
+	err := c.ParseReader(strings.NewReader(`
+root = /srv/${app_name}
+app_name = ego
+[db]
+host = ${ENV:EGO_TEST_HOST}
+port = ${ENV:EGO_TEST_PORT:-3306}
+user = ${ENV:EGO_TEST_EMPTY:-root}
+password = secret://file` + secretFile + `
+password2 = secret://file/` + secretFile + `
+dsn = ${db.user}:${db.password}@tcp(${db.host}:${db.port})
+[db.replica]
+host = replica.${db.host}
+[app]
+log_dir = ${root}/logs
+literal = $${root}
+token = secret://test/api
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = c.Interpolate(); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		section, key, val string
+		secret            bool
+	}{
+		{"", "root", "/srv/ego", false},
+		{"db", "host", "db.local", false},
+		{"db", "port", "3306", false},
+		{"db", "user", "root", false},
+		{"db", "password", "s3cret", true},
+		{"db", "password2", "s3cret", true},
+		{"db", "dsn", "root:s3cret@tcp(db.local:3306)", true},
+		{"db.replica", "host", "replica.db.local", false},
+		{"app", "log_dir", "/srv/ego/logs", false},
+		{"app", "literal", "${root}", false},
+		{"app", "token", "token-api", true},
+	}
+	for _, cs := range cases {
+		if val := c.lookup(cs.section, cs.key); val != cs.val || c.IsSecret(cs.section, cs.key) != cs.secret {
+			t.Errorf("%v:%v = %v secret %v", cs.section, cs.key, val, c.IsSecret(cs.section, cs.key))
+		}
+	}
+	if val := c.Masked("db", "dsn"); val != Mask {
+		t.Errorf("masked = %v", val)
+	}
+
+	nc := New()
+	if err = nc.ParseReader(strings.NewReader("[db]\npassword = other\n")); err != nil {
+		t.Fatal(err)
+	}
+	for _, ch := range Diff(c, nc) {
+		if ch.Section == "db" && ch.Key == "password" && ch.String() != "~ db:password = ****** -> ******" {
+			t.Errorf("change = %v", ch)
+		}
+	}
+}
+
+func TestInterpolateError(t *testing.T) {
+	for conf, want := range map[string]string{
+		"[a]\nx = ${a.y}\ny = ${b.z}\n[b]\nz = ${a.x}\n": "config reference cycle a:x -> a:y -> b:z -> a:x",
+		"[a]\nx = ${a.missing}\n":                        "undefined reference ${a.missing}",
+		"[a]\nx = ${ENV:EGO_TEST_UNSET}\n":               "environment variable EGO_TEST_UNSET",
+		"[a]\nx = ${a.y\n":                               "unterminated reference",
+		"[a]\nx = secret://none/y\n":                     "unknown secret provider none",
+	} {
+		c := New()
+		if err := c.ParseReader(strings.NewReader(conf)); err != nil {
+			t.Fatal(err)
+		}
+		if err := c.Interpolate(); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%q err = %v, want %v", conf, err, want)
+		}
+	}
+}
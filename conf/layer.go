@@ -0,0 +1,274 @@
+package conf
+
+import (
+	"os"
+	"sort"
+	"strings"
+)
+
+// EnvPrefix is the prefix of the environment variables overriding config
+const EnvPrefix = "EGO_"
+
+// Source the layer supplying a config value, the later layer wins:
+// default, file, env, flag
+type Source int
+
+const (
+	SourceNone Source = iota
+	SourceDefault
+	SourceFile
+	SourceEnv
+	SourceFlag
+)
+
+func (s Source) String() string {
+	switch s {
+	case SourceDefault:
+		return "default"
+	case SourceFile:
+		return "file"
+	case SourceEnv:
+		return "env"
+	case SourceFlag:
+		return "flag"
+	}
+	return "none"
+}
+
+// Source return the layer supplying the value of key
+func (s *Section) Source(key string) Source {
+	if src, ok := s.src[key]; ok {
+		return src
+	}
+	if _, ok := s.val[key]; ok {
+		return SourceFile
+	}
+	return SourceNone
+}
+
+// set set the value of key supplied by src
+func (s *Section) set(key, val string, src Source) {
+	if s.val == nil {
+		s.val = make(map[string]string)
+	}
+	if s.src == nil {
+		s.src = make(map[string]Source)
+	}
+	s.val[key] = val
+	s.src[key] = src
+}
+
+// SetDefault set the default value of the key in section, the common
+// config if section is "", it's used if the key is not in the file,
+// the environment variables or the flags. It must be called before Init.
+func (c *Config) SetDefault(section, key, val string) {
+	if c.defaults == nil {
+		c.defaults = make(map[string]map[string]string)
+	}
+	if c.defaults[section] == nil {
+		c.defaults[section] = make(map[string]string)
+	}
+	c.defaults[section][key] = val
+}
+
+// Source return the layer supplying the value of the key in section,
+// the common config if section is ""
+func (c *Config) Source(section, key string) Source {
+	if section == "" {
+		if src, ok := c.commonSrc[key]; ok {
+			return src
+		}
+		if _, ok := c.Common[key]; ok {
+			return SourceFile
+		}
+		return SourceNone
+	}
+	if s := c.Get(section); s != nil {
+		return s.Source(key)
+	}
+	return SourceNone
+}
+
+// setPosition set the position of the key in section parsed from the file
+func (c *Config) setPosition(section, key, pos string) {
+	if c.positions == nil {
+		c.positions = make(map[string]string)
+	}
+	c.positions[section+":"+key] = pos
+}
+
+// position return where the value of the key in section is from, such as
+// "app.conf:12" for the file, or the layer "env", "" if not found
+func (c *Config) position(section, key string) string {
+	switch src := c.Source(section, key); src {
+	case SourceNone:
+		return ""
+	case SourceFile:
+		return c.positions[section+":"+key]
+	default:
+		return src.String()
+	}
+}
+
+// Resolve apply the layers over the parsed file: the defaults are used for
+// the missing keys, then the environment variables and the flags override.
+//
+// An environment variable EGO_<SECTION>_<KEY> override the key of a known
+// section (in the file or the defaults), such as EGO_HTTP_CONF_PORT for
+// http_conf:port, or EGO_<KEY> for an existing common key. The "." of
+// a nested section is "_", such as EGO_DB_REPLICA_HOST for db.replica:host.
+// A flag --section.key=value override the key of any section, the longest
+// known section is matched, such as --db.replica.host=b.
+//
+// The environ is also used by ${ENV:...} and secret://env of Interpolate,
+// and kept for Reload. The process environment is used if it's nil.
+func (c *Config) Resolve(environ, args []string) {
+	c.environ, c.args = environ, args
+
+	for section, kv := range c.defaults {
+		for key, val := range kv {
+			if section == "" {
+				if _, ok := c.Common[key]; !ok {
+					c.setCommon(key, val, SourceDefault)
+				}
+			} else if s := c.section(section); s.Source(key) == SourceNone {
+				s.set(key, val, SourceDefault)
+			}
+		}
+	}
+
+	// match the longest section first, as http_conf is a prefix of http_conf_x
+	sections := make([]string, 0, len(c.Sector))
+	for name := range c.Sector {
+		sections = append(sections, name)
+	}
+	sort.Slice(sections, func(i, j int) bool {
+		return len(sections[i]) > len(sections[j])
+	})
+	for _, env := range environ {
+		idx := strings.Index(env, "=")
+		if idx < 0 || !strings.HasPrefix(env, EnvPrefix) {
+			continue
+		}
+		name, val := strings.ToLower(env[len(EnvPrefix):idx]), env[idx+1:]
+		c.applyEnv(sections, name, val)
+	}
+
+	for _, arg := range args {
+		if arg == "--" {
+			break
+		}
+		if !strings.HasPrefix(arg, "--") {
+			continue
+		}
+		idx := strings.Index(arg, "=")
+		if idx < 0 {
+			continue
+		}
+		name, val := arg[2:idx], arg[idx+1:]
+		section, key := flagKey(sections, name)
+		if section == "" || key == "" {
+			continue
+		}
+		c.section(section).set(key, val, SourceFlag)
+	}
+}
+
+// flagKey split the flag name section.key, the longest known section is
+// matched first, such as db.replica of --db.replica.host, or it's split at
+// the last "." like the references of Interpolate
+func flagKey(sections []string, name string) (string, string) {
+	for _, section := range sections {
+		if strings.HasPrefix(name, section+".") {
+			return section, name[len(section)+1:]
+		}
+	}
+	dot := strings.LastIndex(name, ".")
+	if dot < 0 {
+		return "", ""
+	}
+	return name[:dot], name[dot+1:]
+}
+
+// lookupEnv return the environment variable of the environ of Resolve,
+// or the process environment if it's nil
+func (c *Config) lookupEnv(name string) (string, bool) {
+	if c.environ == nil {
+		return os.LookupEnv(name)
+	}
+	for _, env := range c.environ {
+		if strings.HasPrefix(env, name+"=") {
+			return env[len(name)+1:], true
+		}
+	}
+	return "", false
+}
+
+// applyEnv apply the environment variable of lower case name, the "." of
+// the section names are "_", such as EGO_DB_REPLICA_HOST for db.replica:host
+func (c *Config) applyEnv(sections []string, name, val string) {
+	for _, section := range sections {
+		prefix := strings.ToLower(strings.Replace(section, ".", "_", -1)) + "_"
+		if !strings.HasPrefix(name, prefix) || len(name) == len(prefix) {
+			continue
+		}
+		s := c.Sector[section]
+		key := name[len(prefix):]
+		for k := range s.val {
+			if strings.ToLower(k) == key {
+				key = k
+				break
+			}
+		}
+		s.set(key, val, SourceEnv)
+		return
+	}
+	for k := range c.Common {
+		if strings.ToLower(k) == name {
+			c.setCommon(k, val, SourceEnv)
+			return
+		}
+	}
+}
+
+func (c *Config) setCommon(key, val string, src Source) {
+	if c.commonSrc == nil {
+		c.commonSrc = make(map[string]Source)
+	}
+	c.Common[key] = val
+	c.commonSrc[key] = src
+}
+
+// section return the section, it's created if not found
+func (c *Config) section(name string) *Section {
+	s, ok := c.Sector[name]
+	if !ok {
+		s = &Section{
+			delimit: c.Delimit,
+			sector:  name,
+			val:     make(map[string]string),
+		}
+		c.Sector[name] = s
+	}
+	return s
+}
+
+// SetDefault set the default value of the key in section of the global
+// config, it must be called before Init
+func SetDefault(section, key, val string) {
+	global().SetDefault(section, key, val)
+}
+
+// SourceOf return the layer supplying the value of the key in section of
+// the global config
+func SourceOf(section, key string) Source {
+	return global().Source(section, key)
+}
+
+// osArgs return the command line arguments without the program name
+func osArgs() []string {
+	if len(os.Args) > 1 {
+		return os.Args[1:]
+	}
+	return nil
+}
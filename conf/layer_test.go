@@ -0,0 +1,112 @@
+package conf
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestResolve(t *testing.T) {
+	c := New()
+	c.SetDefault("http_conf", "port", "80")
+	c.SetDefault("http_conf", "timeout", "30s")
+	c.SetDefault("redis", "addr", "127.0.0.1:6379")
+	c.SetDefault("", "test_mode", "0")
+	err := c.ParseReader(strings.NewReader(`
+cors_domain=*
+[http_conf]
+port=8080
+max_body_size=1m
+[http_conf_admin]
+port=9090
+[db]
+dsn=root@/ego
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Resolve([]string{
+		"EGO_HTTP_CONF_MAX_BODY_SIZE=2m",
+		"EGO_HTTP_CONF_ADMIN_PORT=9091",
+		"EGO_DB_DSN=root:secret@/ego",
+		"EGO_CORS_DOMAIN=a.com",
+		"EGO_UNKNOWN_KEY=1",
+		"PATH=/bin",
+	}, []string{"-v", "--check-config", "--http_conf.port=8000", "--db.dsn=flag", "--", "--http_conf.timeout=1s"})
+
+	cases := []struct {
+		section, key, val string
+		src               Source
+	}{
+		{"http_conf", "port", "8000", SourceFlag},
+		{"http_conf", "timeout", "30s", SourceDefault},
+		{"http_conf", "max_body_size", "2m", SourceEnv},
+		{"http_conf_admin", "port", "9091", SourceEnv},
+		{"db", "dsn", "flag", SourceFlag},
+		{"redis", "addr", "127.0.0.1:6379", SourceDefault},
+		{"", "cors_domain", "a.com", SourceEnv},
+		{"", "test_mode", "0", SourceDefault},
+	}
+	for _, cs := range cases {
+		var val string
+		if cs.section == "" {
+			val = c.GetKey(cs.key)
+		} else {
+			val, _ = c.Get(cs.section).String(cs.key)
+		}
+		if val != cs.val || c.Source(cs.section, cs.key) != cs.src {
+			t.Errorf("%v:%v = %v from %v, want %v from %v", cs.section, cs.key, val, c.Source(cs.section, cs.key), cs.val, cs.src)
+		}
+	}
+	if c.Get("unknown") != nil {
+		t.Error("env created an unknown section")
+	}
+	if src := c.Source("db", "missing"); src != SourceNone {
+		t.Errorf("missing key source = %v", src)
+	}
+
+	var v struct {
+		Port    int64  `json:"http_conf:port"`
+		Timeout int64  `json:"http_conf:timeout:time"`
+		DSN     string `json:"db:dsn"`
+	}
+	if err = c.Unmarshal(&v, "json"); err != nil {
+		t.Fatal(err)
+	}
+	if v.Port != 8000 || v.Timeout != int64(30e9) || v.DSN != "flag" {
+		t.Errorf("unmarshal = %+v", v)
+	}
+}
+
+func TestResolveNested(t *testing.T) {
+	c := New()
+	err := c.ParseReader(strings.NewReader(`
+[db]
+host = a
+[db.replica]
+host = a
+port = 3306
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Resolve([]string{"EGO_DB_REPLICA_PORT=3307"}, []string{"--db.replica.host=b", "--cache.local.size=1"})
+
+	cases := []struct {
+		section, key, val string
+		src               Source
+	}{
+		{"db.replica", "host", "b", SourceFlag},
+		{"db.replica", "port", "3307", SourceEnv},
+		{"db", "host", "a", SourceFile},
+		{"cache.local", "size", "1", SourceFlag},
+	}
+	for _, cs := range cases {
+		val, _ := c.Get(cs.section).String(cs.key)
+		if val != cs.val || c.Source(cs.section, cs.key) != cs.src {
+			t.Errorf("%v:%v = %v from %v, want %v from %v", cs.section, cs.key, val, c.Source(cs.section, cs.key), cs.val, cs.src)
+		}
+	}
+	if _, err = c.Get("db").String("replica.host"); err == nil {
+		t.Error("the flag is set to db:replica.host")
+	}
+}
@@ -0,0 +1,95 @@
+package conf
+
+import (
+	"os"
+)
+
+// LoadOptions the options of Load
+type LoadOptions struct {
+	// the environment variables and the command line arguments overriding
+	// the file, see Config.Resolve. os.Environ() and os.Args if nil, an
+	// empty slice disables the layer.
+	Environ []string
+	Args    []string
+	// the default values by section and key, the common config if the
+	// section is "", see Config.SetDefault
+	Defaults map[string]map[string]string
+	// see Config.Strict
+	Strict bool
+}
+
+// Load parse the config file like Init, but the config is returned instead
+// of replacing the global one, so it can be passed to the constructors of
+// log, orm, queue and service explicitly.
+//
+//	c, err := conf.Load("app.conf", conf.LoadOptions{Args: []string{}})
+//	if err != nil {
+//		...
+//	}
+//	s := service.NewHttpService(service.ServiceOptions{Config: c})
+func Load(file string, op ...LoadOptions) (*Config, error) {
+	var opt LoadOptions
+	if len(op) > 0 {
+		opt = op[0]
+	}
+	if opt.Environ == nil {
+		opt.Environ = os.Environ()
+	}
+	if opt.Args == nil {
+		opt.Args = osArgs()
+	}
+
+	c := New()
+	c.Strict = opt.Strict
+	for section, kv := range opt.Defaults {
+		for key, val := range kv {
+			c.SetDefault(section, key, val)
+		}
+	}
+	if err := c.Parse(file); err != nil {
+		return nil, err
+	}
+	c.Resolve(opt.Environ, opt.Args)
+	if err := c.Interpolate(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// FromMap return a config of the sections, the common config is the section
+// "". It's mostly used by tests.
+//
+//	c := conf.FromMap(map[string]map[string]string{
+//		"":          {"app_name": "ego"},
+//		"http_conf": {"port": "8080"},
+//	})
+func FromMap(m map[string]map[string]string) *Config {
+	c := New()
+	for name, kv := range m {
+		if name == "" {
+			for key, val := range kv {
+				c.Common[key] = val
+			}
+			continue
+		}
+		s := c.section(name)
+		for key, val := range kv {
+			s.val[key] = val
+		}
+	}
+	return c
+}
+
+// Global return the global config, it's replaced by Init and Reload, so it
+// should not be kept
+func Global() *Config {
+	return global()
+}
+
+// SetGlobal replace the global config by c, such as a config of Load or
+// FromMap. The subscribers of OnChange are not notified.
+func SetGlobal(c *Config) {
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+	gconf.Store(c)
+}
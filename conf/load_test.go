@@ -0,0 +1,113 @@
+package conf
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestLoad(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"app.conf": "name = ${app.name}\n[app]\nname = ego\nport = 80\n",
+	})
+	file := filepath.Join(dir, "app.conf")
+	old := Global()
+
+	c, err := Load(file, LoadOptions{
+		Environ:  []string{"EGO_APP_PORT=8080"},
+		Args:     []string{"--app.mode=dev"},
+		Defaults: map[string]map[string]string{"app": {"timeout": "5s"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if Global() != old {
+		t.Error("the global config is replaced")
+	}
+	if c.File != file || c.GetKey("name") != "ego" {
+		t.Errorf("file = %v, name = %v", c.File, c.GetKey("name"))
+	}
+	app := c.Get("app")
+	for key, want := range map[string]string{"port": "8080", "mode": "dev", "timeout": "5s"} {
+		if v, _ := app.String(key); v != want {
+			t.Errorf("app:%v = %v, want %v", key, v, want)
+		}
+	}
+
+	// no environment variables and flags
+	c, err = Load(file, LoadOptions{Environ: []string{}, Args: []string{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if port, _ := c.Get("app").String("port"); port != "80" {
+		t.Errorf("port = %v", port)
+	}
+
+	if _, err = Load(filepath.Join(dir, "none.conf")); err == nil {
+		t.Error("load a missing file")
+	}
+}
+
+func TestFromMap(t *testing.T) {
+	c := FromMap(map[string]map[string]string{
+		"":          {"app_name": "ego"},
+		"http_conf": {"port": "8080", "cors": "a.com,b.com"},
+	})
+	if c.GetKey("app_name") != "ego" {
+		t.Errorf("common = %v", c.Common)
+	}
+	port, _ := c.Get("http_conf").Int("port")
+	cors, _ := c.Get("http_conf").Strings("cors")
+	if port != 8080 || len(cors) != 2 {
+		t.Errorf("port = %v, cors = %v", port, cors)
+	}
+	if src := c.Source("http_conf", "port"); src != SourceFile {
+		t.Errorf("source = %v", src)
+	}
+
+	old := Global()
+	defer SetGlobal(old)
+	SetGlobal(c)
+	if GetKey("app_name") != "ego" {
+		t.Error("the global config is not replaced")
+	}
+}
+
+func TestLoadEnviron(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"app.conf": "[http_conf]\nport = 1\nhost = ${ENV:EGO_TEST_LOAD_HOST:-localhost}\npassword = secret://env/EGO_TEST_LOAD_PASSWORD\n",
+	})
+	file := filepath.Join(dir, "app.conf")
+	t.Setenv("EGO_HTTP_CONF_PORT", "9")
+	t.Setenv("EGO_TEST_LOAD_HOST", "os.local")
+	t.Setenv("EGO_TEST_LOAD_PASSWORD", "os")
+
+	c, err := Load(file, LoadOptions{Environ: []string{"EGO_TEST_LOAD_PASSWORD=p"}, Args: []string{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	check := func(c *Config) {
+		t.Helper()
+		s := c.Get("http_conf")
+		port, _ := s.String("port")
+		host, _ := s.String("host")
+		password, _ := s.String("password")
+		if port != "1" || host != "localhost" || password != "p" {
+			t.Errorf("port = %v, host = %v, password = %v", port, host, password)
+		}
+	}
+	check(c)
+
+	// the environ is kept by Reload and DiffFile
+	nc, err := c.Reload()
+	if err != nil {
+		t.Fatal(err)
+	}
+	check(nc)
+	if changes, err := c.DiffFile(file); err != nil || len(changes) != 0 {
+		t.Errorf("changes = %v, err = %v", changes, err)
+	}
+
+	if _, err = Load(file, LoadOptions{Environ: []string{}, Args: []string{}}); err == nil {
+		t.Error("secret://env is resolved by the process environment")
+	}
+}
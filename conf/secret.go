@@ -0,0 +1,115 @@
+package conf
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+const (
+	// SecretPrefix the prefix of a secret reference value such as
+	// "secret://file/run/secrets/db" or "secret://env/DB_PASSWORD"
+	SecretPrefix = "secret://"
+	// Mask replace the secret values when the config is dumped or logged
+	Mask = "******"
+)
+
+// SecretProvider resolve the secret references of a provider name, the
+// ref is the rest of "secret://<name>/<ref>"
+type SecretProvider interface {
+	Secret(ref string) (string, error)
+}
+
+// SecretFunc adapt a function to SecretProvider
+type SecretFunc func(ref string) (string, error)
+
+func (f SecretFunc) Secret(ref string) (string, error) {
+	return f(ref)
+}
+
+var (
+	secretsMu       sync.RWMutex
+	secretProviders = map[string]SecretProvider{
+		// the ref is an absolute file path, "secret://file/run/secrets/db"
+		// reads /run/secrets/db, the trailing newline is trimmed
+		"file": SecretFunc(func(ref string) (string, error) {
+			data, err := os.ReadFile(filepath.Join("/", ref))
+			if err != nil {
+				return "", err
+			}
+			return strings.TrimRight(string(data), "\r\n"), nil
+		}),
+		// the ref is an environment variable
+		"env": envSecret{},
+	}
+)
+
+// envSecret resolve the environment variables by lookup, the environ of
+// the config is used when resolved by Interpolate
+type envSecret struct {
+	lookup func(name string) (string, bool)
+}
+
+func (p envSecret) Secret(ref string) (string, error) {
+	lookup := p.lookup
+	if lookup == nil {
+		lookup = os.LookupEnv
+	}
+	val, ok := lookup(ref)
+	if !ok {
+		return "", fmt.Errorf("environment variable %s is not set", ref)
+	}
+	return val, nil
+}
+
+// RegisterSecretProvider register the provider of "secret://<name>/...",
+// such as a vault client. The builtin providers are "file" and "env".
+func RegisterSecretProvider(name string, p SecretProvider) {
+	secretsMu.Lock()
+	defer secretsMu.Unlock()
+	secretProviders[name] = p
+}
+
+// resolveSecret resolve the secret reference value
+func (c *Config) resolveSecret(val string) (string, error) {
+	ref := val[len(SecretPrefix):]
+	idx := strings.Index(ref, "/")
+	if idx <= 0 {
+		return "", fmt.Errorf("invalid secret reference %s, must be secret://<provider>/<ref>", val)
+	}
+	name := ref[:idx]
+	secretsMu.RLock()
+	p, ok := secretProviders[name]
+	secretsMu.RUnlock()
+	if !ok {
+		return "", fmt.Errorf("unknown secret provider %s of %s", name, val)
+	}
+	if _, ok := p.(envSecret); ok {
+		p = envSecret{lookup: c.lookupEnv}
+	}
+	secret, err := p.Secret(ref[idx+1:])
+	if err != nil {
+		return "", fmt.Errorf("secret %s: %v", val, err)
+	}
+	return secret, nil
+}
+
+// IsSecret return whether the value of the key in section is resolved from
+// a secret reference, or interpolated with a secret value
+func (c *Config) IsSecret(section, key string) bool {
+	return c.secrets[section+":"+key]
+}
+
+// Masked return the value of the key in section, or Mask if it's secret
+func (c *Config) Masked(section, key string) string {
+	if c.IsSecret(section, key) {
+		return Mask
+	}
+	if section == "" {
+		return c.Common[key]
+	}
+	val, _ := c.value(section, key)
+	return val
+}
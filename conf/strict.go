@@ -0,0 +1,324 @@
+package conf
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+// SyntaxError a malformed line in strict mode
+type SyntaxError struct {
+	File string
+	Line int
+	Col  int
+	Msg  string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("%s:%d:%d: %s", e.File, e.Line, e.Col, e.Msg)
+}
+
+var heredocTag = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// parseStrict parse the conf format strictly, all the problems are
+// returned at once as ValidationErrors. Besides ParseReader it supports:
+//
+//	# the quoted values with the escapes of Go, the literal single quoted
+//	name = "a \"quoted\" value\n"
+//	path = 'C:\path'
+//
+//	# the inline comments after a space
+//	port = 8080 # the http port
+//
+//	# the continuation lines, the leading spaces of the next line are trimmed
+//	domains = a.com,\
+//	          b.com
+//
+//	# the heredoc, the lines are kept verbatim
+//	banner = <<EOF
+//	  hello
+//	EOF
+func (c *Config) parseStrict(reader io.Reader, file string, chain []string) error {
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return err
+	}
+	var (
+		lines   = strings.Split(strings.Replace(string(data), "\r\n", "\n", -1), "\n")
+		errs    ValidationErrors
+		section string
+	)
+	errorf := func(line, col int, format string, args ...interface{}) {
+		errs = append(errs, &SyntaxError{file, line, col, fmt.Sprintf(format, args...)})
+	}
+
+	for i := 0; i < len(lines); i++ {
+		line := i + 1
+		row := strings.TrimSpace(lines[i])
+		col := strings.Index(lines[i], row) + 1
+		if row == "" || strings.HasPrefix(row, c.Comment) {
+			continue
+		}
+
+		if strings.HasPrefix(row, SectionB) {
+			end := strings.Index(row, SectionE)
+			if end < 0 {
+				errorf(line, col+len(row), "no end sector %s", SectionE)
+				continue
+			}
+			if rest := strings.TrimSpace(row[end+1:]); rest != "" && !strings.HasPrefix(rest, c.Comment) {
+				errorf(line, col+strings.Index(row, rest), "unexpected %q after section", rest)
+				continue
+			}
+			name := strings.TrimSpace(row[1:end])
+			if name == "" {
+				errorf(line, col+1, "empty section name")
+				continue
+			}
+			if _, ok := c.Sector[name]; ok {
+				errorf(line, col+1, "sector key %v already exists", name)
+			}
+			c.section(name)
+			c.setPosition(name, "", fmt.Sprintf("%v:%v:%v", file, line, col))
+			section = name
+			continue
+		}
+
+		if pattern, optional, ok := c.includeRow(c.stripComment(row)); ok {
+			if pattern == "" {
+				errorf(line, col, "no file to include")
+				continue
+			}
+			if err = c.include(pattern, file, optional, chain); err != nil {
+				if es, ok := err.(ValidationErrors); ok {
+					for _, e := range es {
+						errs = append(errs, fmt.Errorf("%v, included from %v:%v", e, file, line))
+					}
+				} else {
+					errs = append(errs, fmt.Errorf("%v, included from %v:%v", err, file, line))
+				}
+			}
+			continue
+		}
+
+		idx := strings.Index(row, c.Split)
+		if idx < 0 {
+			errorf(line, col, "expected key %s value", c.Split)
+			continue
+		}
+		key := strings.TrimSpace(row[:idx])
+		if key == "" {
+			errorf(line, col, "empty key")
+			continue
+		}
+		if strings.ContainsAny(key, " \t\"'") {
+			errorf(line, col, "invalid key %q", key)
+			continue
+		}
+		rest := row[idx+len(c.Split):]
+		val, next, serr := c.strictValue(lines, i, rest, col+idx+len(c.Split))
+		if serr != nil {
+			serr.File = file
+			errs = append(errs, serr)
+			i = next
+			continue
+		}
+		i = next
+
+		pos := fmt.Sprintf("%v:%v:%v", file, line, col)
+		if section == "" {
+			if _, ok := c.Common[key]; ok {
+				errorf(line, col, "same common key %v", key)
+				continue
+			}
+			c.Common[key] = val
+		} else {
+			s := c.Sector[section]
+			if _, ok := s.val[key]; ok {
+				errorf(line, col, "section %s already has key: %s", section, key)
+				continue
+			}
+			s.val[key] = val
+		}
+		c.setPosition(section, key, pos)
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// strictValue parse the value starting at lines[i][col-1:], it returns the
+// value and the index of the last line of the value
+func (c *Config) strictValue(lines []string, i int, rest string, col int) (string, int, *SyntaxError) {
+	s := strings.TrimLeft(rest, " \t")
+	col += len(rest) - len(s)
+	line := i + 1
+	if s == "" {
+		return "", i, nil
+	}
+
+	switch s[0] {
+	case '"', '\'':
+		end := quoteEnd(s)
+		if end < 0 {
+			return "", i, &SyntaxError{Line: line, Col: col, Msg: "unterminated quoted value"}
+		}
+		if tail := strings.TrimSpace(s[end+1:]); tail != "" && !strings.HasPrefix(tail, c.Comment) {
+			return "", i, &SyntaxError{Line: line, Col: col + end + 1, Msg: fmt.Sprintf("unexpected %q after quoted value", tail)}
+		}
+		if s[0] == '\'' {
+			return s[1:end], i, nil
+		}
+		val, err := strconv.Unquote(s[:end+1])
+		if err != nil {
+			return "", i, &SyntaxError{Line: line, Col: col, Msg: "invalid escape in quoted value"}
+		}
+		return val, i, nil
+	}
+
+	if strings.HasPrefix(s, "<<") {
+		tag := strings.TrimSpace(s[2:])
+		if !heredocTag.MatchString(tag) {
+			return "", i, &SyntaxError{Line: line, Col: col + 2, Msg: fmt.Sprintf("invalid heredoc tag %q", tag)}
+		}
+		for j := i + 1; j < len(lines); j++ {
+			if strings.TrimSpace(lines[j]) == tag {
+				return strings.Join(lines[i+1:j], "\n"), j, nil
+			}
+		}
+		return "", len(lines), &SyntaxError{Line: line, Col: col, Msg: fmt.Sprintf("unterminated heredoc %s", tag)}
+	}
+
+	val := c.stripComment(s)
+	for strings.HasSuffix(val, `\`) {
+		val = val[:len(val)-1]
+		if i+1 >= len(lines) {
+			return "", i, &SyntaxError{Line: line, Col: col + len(val), Msg: "continuation at the end of file"}
+		}
+		i++
+		val += c.stripComment(strings.TrimSpace(lines[i]))
+	}
+	return strings.TrimSpace(val), i, nil
+}
+
+// stripComment remove the inline comment after a space
+func (c *Config) stripComment(s string) string {
+	for i := 1; i < len(s); i++ {
+		if (s[i-1] == ' ' || s[i-1] == '\t') && strings.HasPrefix(s[i:], c.Comment) {
+			return strings.TrimRight(s[:i], " \t")
+		}
+	}
+	return s
+}
+
+// quoteEnd return the index of the closing quote of s
+func quoteEnd(s string) int {
+	for i := 1; i < len(s); i++ {
+		switch {
+		case s[0] == '"' && s[i] == '\\':
+			i++
+		case s[i] == s[0]:
+			return i
+		}
+	}
+	return -1
+}
+
+// checkStrict check the sections and keys of the file against the
+// registered schemas in strict mode. A section is unknown if it's neither
+// in a schema nor declared by RegisterSection, the keys of the declared
+// sections are not checked.
+func (c *Config) checkStrict() error {
+	if !c.Strict {
+		return nil
+	}
+	subsMu.Lock()
+	ss := append([]schema{}, schemas...)
+	declared := make(map[string]bool, len(knownSections))
+	for name := range knownSections {
+		declared[name] = true
+	}
+	subsMu.Unlock()
+	if len(ss) == 0 {
+		return nil
+	}
+
+	known := map[string]map[string]bool{}
+	for _, s := range ss {
+		schemaKeys(s.typ, "", s.flag, nil, known)
+	}
+	var errs ValidationErrors
+	for _, name := range c.sectionNames() {
+		keys, ok := known[name]
+		if !ok {
+			if pos, inFile := c.positions[name+":"]; inFile && !declared[name] {
+				errs = append(errs, fmt.Errorf("%s: unknown section %v", pos, name))
+			}
+			continue
+		}
+		unknown := []string{}
+		for key := range c.Sector[name].val {
+			if !keys[key] && c.Source(name, key) == SourceFile {
+				unknown = append(unknown, key)
+			}
+		}
+		sort.Strings(unknown)
+		for _, key := range unknown {
+			errs = append(errs, fmt.Errorf("%s: unknown key %v in section %v", c.positions[name+":"+key], key, name))
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// schemaKeys add the sections and keys of the struct type t to known
+func schemaKeys(t reflect.Type, section, flag string, stack []reflect.Type, known map[string]map[string]bool) {
+	stack = append(stack, t)
+	for i := 0; i < t.NumField(); i++ {
+		tf := t.Field(i)
+		tag := tf.Tag.Get(flag)
+		if tag == "-" || (tf.PkgPath != "" && !tf.Anonymous) {
+			continue
+		}
+		if tag == "" || tag == "omitempty" {
+			if !isNested(tf.Type) || inStack(stack, tf.Type) {
+				continue
+			}
+			nt := tf.Type
+			if nt.Kind() == reflect.Ptr {
+				nt = nt.Elem()
+			}
+			schemaKeys(nt, joinSection(section, tf.Tag.Get("section")), flag, stack, known)
+			continue
+		}
+		tagArr, err := fieldTag(tag, section)
+		if err != nil {
+			continue
+		}
+		if known[tagArr[0]] == nil {
+			known[tagArr[0]] = map[string]bool{}
+		}
+		known[tagArr[0]][tagArr[1]] = true
+	}
+}
+
+// strictMode the strict mode of Init, 1 if set by SetStrict
+var strictMode int32
+
+// SetStrict set the strict mode of the config parsed by Init, it must be
+// called before Init, see Config.Strict
+func SetStrict(strict bool) {
+	var v int32
+	if strict {
+		v = 1
+	}
+	atomic.StoreInt32(&strictMode, v)
+}
@@ -0,0 +1,144 @@
+package conf
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestStrict(t *testing.T) {
+	c := New()
+	c.Strict = true
+	err := c.ParseReader(strings.NewReader(`
+name = "a \"quoted\" value\n" # comment
+path = 'C:\path' # comment
+url = http://a.com/#anchor # comment
+domains = a.com,\
+          b.com,\
+          c.com
+[s]
+  banner = <<EOF
+  hello
+    world
+  EOF
+empty =
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := map[string]string{
+		"name":    "a \"quoted\" value\n",
+		"path":    `C:\path`,
+		"url":     "http://a.com/#anchor",
+		"domains": "a.com,b.com,c.com",
+	}
+	for key, want := range cases {
+		if val := c.GetKey(key); val != want {
+			t.Errorf("%v = %q, want %q", key, val, want)
+		}
+	}
+	if banner, _ := c.Get("s").String("banner"); banner != "  hello\n    world" {
+		t.Errorf("banner = %q", banner)
+	}
+	if pos := c.position("s", "banner"); pos != ":9:3" {
+		t.Errorf("position = %v", pos)
+	}
+
+	c = New()
+	c.Strict = true
+	c.File = "app.conf"
+	err = c.ParseReader(strings.NewReader(`a = 1
+typo
+= 2
+a = 3
+[s
+[s] x
+[t]
+k = "unterminated
+k2 = "bad \q"
+k3 = 'x' y
+k4 = <<EOF
+`))
+	errs, ok := err.(ValidationErrors)
+	if !ok {
+		t.Fatalf("err = %v", err)
+	}
+	want := []string{
+		"app.conf:2:1: expected key = value",
+		"app.conf:3:1: empty key",
+		"app.conf:4:1: same common key a",
+		"app.conf:5:3: no end sector ]",
+		`app.conf:6:5: unexpected "x" after section`,
+		"app.conf:8:5: unterminated quoted value",
+		"app.conf:9:6: invalid escape in quoted value",
+		`app.conf:10:9: unexpected "y" after quoted value`,
+		"app.conf:11:6: unterminated heredoc EOF",
+	}
+	if len(errs) != len(want) {
+		t.Fatalf("errs = %v", err)
+	}
+	for i, e := range errs {
+		if e.Error() != want[i] {
+			t.Errorf("err %d = %v, want %v", i, e, want[i])
+		}
+	}
+
+	// the lenient mode ignores the malformed rows
+	c = New()
+	if err = c.ParseReader(strings.NewReader("a = 1\ntypo\nb = 2\n")); err != nil || c.GetKey("a") != "1" || len(c.Common) != 2 {
+		t.Errorf("err = %v, common = %v", err, c.Common)
+	}
+}
+
+func TestStrictSchema(t *testing.T) {
+	subsMu.Lock()
+	oldSchemas, oldSections := schemas, knownSections
+	knownSections = map[string]bool{}
+	subsMu.Unlock()
+	defer func() {
+		subsMu.Lock()
+		schemas, knownSections = oldSchemas, oldSections
+		subsMu.Unlock()
+	}()
+
+	type schema struct {
+		Port int `json:"http_conf:port"`
+		DB   struct {
+			DSN string `json:"dsn"`
+		} `section:"db"`
+	}
+	RegisterSchema(&schema{})
+
+	dir := writeFiles(t, map[string]string{
+		"app.conf":  "include db.yaml\n[http_conf]\nport = 80\nprot = 81\n[cache]\n",
+		"db.yaml":   "db:\n  dsn: x\n  user: root\n",
+		"good.conf": "[http_conf]\nport = 80\n",
+		"init.conf": "[http_conf]\nport = 80 # inline comment\n[log]\nlevel = debug\n",
+	})
+	c := New()
+	c.Strict = true
+	err := c.Parse(filepath.Join(dir, "app.conf"))
+	want := filepath.Join(dir, "app.conf") + ":5:1: unknown section cache\n" +
+		filepath.Join(dir, "db.yaml") + ": unknown key user in section db\n" +
+		filepath.Join(dir, "app.conf") + ":4:1: unknown key prot in section http_conf"
+	if err == nil || err.Error() != want {
+		t.Errorf("err = %v", err)
+	}
+
+	c = New()
+	c.Strict = true
+	if err = c.Parse(filepath.Join(dir, "good.conf")); err != nil {
+		t.Error(err)
+	}
+
+	// the keys of the declared sections are not checked
+	RegisterSection("log")
+	old := Global()
+	defer SetGlobal(old)
+	SetStrict(true)
+	defer SetStrict(false)
+	Init(filepath.Join(dir, "init.conf"))
+	if port, _ := Get("http_conf").String("port"); port != "80" || !Global().Strict {
+		t.Errorf("port = %q, strict = %v", port, Global().Strict)
+	}
+}
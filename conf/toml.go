@@ -0,0 +1,523 @@
+package conf
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// decodeTOML decode the toml file: tables, arrays of tables, bare, quoted
+// and dotted keys, strings, integers, floats, booleans, datetimes, arrays
+// and inline tables. The integers are converted to decimal, the floats and
+// datetimes are kept as written.
+func decodeTOML(data []byte) (map[string]interface{}, error) {
+	p := &tomlParser{s: strings.Replace(string(data), "\r\n", "\n", -1), line: 1}
+	p.tables, p.arrays = map[string]bool{}, map[string]bool{}
+	root := map[string]interface{}{}
+	cur := root
+	for {
+		p.skipBlank()
+		if p.eof() {
+			return root, nil
+		}
+		var err error
+		if p.peek() == '[' {
+			cur, err = p.parseHeader(root)
+		} else {
+			err = p.parseKeyValue(cur)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if err = p.endOfLine(); err != nil {
+			return nil, err
+		}
+	}
+}
+
+type tomlParser struct {
+	s    string
+	pos  int
+	line int
+	// the paths of the tables defined by [table], to reject a table
+	// defined twice, and the arrays of tables defined by [[array]]
+	tables map[string]bool
+	arrays map[string]bool
+}
+
+func (p *tomlParser) eof() bool {
+	return p.pos >= len(p.s)
+}
+
+func (p *tomlParser) peek() byte {
+	if p.eof() {
+		return 0
+	}
+	return p.s[p.pos]
+}
+
+func (p *tomlParser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("line %d: %s", p.line, fmt.Sprintf(format, args...))
+}
+
+// skipSpace skip the spaces and tabs
+func (p *tomlParser) skipSpace() {
+	for !p.eof() && (p.s[p.pos] == ' ' || p.s[p.pos] == '\t') {
+		p.pos++
+	}
+}
+
+// skipComment skip the comment to the end of line
+func (p *tomlParser) skipComment() {
+	if p.peek() == '#' {
+		for !p.eof() && p.s[p.pos] != '\n' {
+			p.pos++
+		}
+	}
+}
+
+// skipBlank skip the spaces, comments and newlines
+func (p *tomlParser) skipBlank() {
+	for {
+		p.skipSpace()
+		p.skipComment()
+		if p.peek() != '\n' {
+			return
+		}
+		p.pos++
+		p.line++
+	}
+}
+
+// endOfLine expect the end of line after a key/value or header
+func (p *tomlParser) endOfLine() error {
+	p.skipSpace()
+	p.skipComment()
+	if p.eof() {
+		return nil
+	}
+	if p.peek() != '\n' {
+		return p.errorf("expected the end of line, found %q", p.rest())
+	}
+	p.pos++
+	p.line++
+	return nil
+}
+
+// rest return the rest of the current line
+func (p *tomlParser) rest() string {
+	end := strings.IndexByte(p.s[p.pos:], '\n')
+	if end < 0 {
+		return p.s[p.pos:]
+	}
+	return p.s[p.pos : p.pos+end]
+}
+
+// parseHeader parse [table] or [[array]], return the current table
+func (p *tomlParser) parseHeader(root map[string]interface{}) (map[string]interface{}, error) {
+	array := strings.HasPrefix(p.s[p.pos:], "[[")
+	if array {
+		p.pos += 2
+	} else {
+		p.pos++
+	}
+	keys, err := p.parseKey()
+	if err != nil {
+		return nil, err
+	}
+	if array {
+		if !strings.HasPrefix(p.s[p.pos:], "]]") {
+			return nil, p.errorf("expected ']]'")
+		}
+		p.pos += 2
+	} else {
+		if p.peek() != ']' {
+			return nil, p.errorf("expected ']'")
+		}
+		p.pos++
+	}
+
+	parent, path, err := p.descend(root, "", keys[:len(keys)-1])
+	if err != nil {
+		return nil, err
+	}
+	last := keys[len(keys)-1]
+	path = joinPath(path, last)
+	if array {
+		arr, ok := parent[last]
+		if ok && !p.arrays[path] {
+			return nil, p.errorf("key %v is not an array of tables", strings.Join(keys, "."))
+		}
+		tables, _ := arr.([]interface{})
+		t := map[string]interface{}{}
+		parent[last] = append(tables, t)
+		p.arrays[path] = true
+		return t, nil
+	}
+
+	if p.tables[path] {
+		return nil, p.errorf("table %v is defined twice", strings.Join(keys, "."))
+	}
+	p.tables[path] = true
+	if v, ok := parent[last]; ok {
+		t, isTable := v.(map[string]interface{})
+		if !isTable {
+			return nil, p.errorf("key %v is not a table", strings.Join(keys, "."))
+		}
+		return t, nil
+	}
+	t := map[string]interface{}{}
+	parent[last] = t
+	return t, nil
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// descend walk into the tables of keys from the table of path, creating
+// the missing ones, the last table of an array of tables is used
+func (p *tomlParser) descend(t map[string]interface{}, path string, keys []string) (map[string]interface{}, string, error) {
+	for i, k := range keys {
+		path = joinPath(path, k)
+		v, ok := t[k]
+		if !ok {
+			nt := map[string]interface{}{}
+			t[k] = nt
+			t = nt
+			continue
+		}
+		switch val := v.(type) {
+		case map[string]interface{}:
+			t = val
+		case []interface{}:
+			if !p.arrays[path] {
+				return nil, "", p.errorf("key %v is not a table", strings.Join(keys[:i+1], "."))
+			}
+			path = joinPath(path, strconv.Itoa(len(val)-1))
+			t = val[len(val)-1].(map[string]interface{})
+		default:
+			return nil, "", p.errorf("key %v is not a table", strings.Join(keys[:i+1], "."))
+		}
+	}
+	return t, path, nil
+}
+
+// parseKeyValue parse key = value into t
+func (p *tomlParser) parseKeyValue(t map[string]interface{}) error {
+	keys, err := p.parseKey()
+	if err != nil {
+		return err
+	}
+	if p.peek() != '=' {
+		return p.errorf("expected '=' after key %v", strings.Join(keys, "."))
+	}
+	p.pos++
+	p.skipSpace()
+	val, err := p.parseValue()
+	if err != nil {
+		return err
+	}
+	parent, _, err := p.descend(t, "", keys[:len(keys)-1])
+	if err != nil {
+		return err
+	}
+	last := keys[len(keys)-1]
+	if _, ok := parent[last]; ok {
+		return p.errorf("duplicate key %v", strings.Join(keys, "."))
+	}
+	parent[last] = val
+	return nil
+}
+
+// parseKey parse a bare, quoted or dotted key
+func (p *tomlParser) parseKey() ([]string, error) {
+	var keys []string
+	for {
+		p.skipSpace()
+		var key string
+		switch c := p.peek(); {
+		case c == '"':
+			s, err := p.parseBasicString()
+			if err != nil {
+				return nil, err
+			}
+			key = s
+		case c == '\'':
+			s, err := p.parseLiteralString()
+			if err != nil {
+				return nil, err
+			}
+			key = s
+		default:
+			start := p.pos
+			for !p.eof() && isBareKeyChar(p.s[p.pos]) {
+				p.pos++
+			}
+			if start == p.pos {
+				return nil, p.errorf("invalid key %q", p.rest())
+			}
+			key = p.s[start:p.pos]
+		}
+		keys = append(keys, key)
+		p.skipSpace()
+		if p.peek() != '.' {
+			return keys, nil
+		}
+		p.pos++
+	}
+}
+
+func isBareKeyChar(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_' || c == '-'
+}
+
+func (p *tomlParser) parseValue() (interface{}, error) {
+	switch c := p.peek(); {
+	case p.eof() || c == '\n':
+		return nil, p.errorf("missing value")
+	case strings.HasPrefix(p.s[p.pos:], `"""`):
+		return p.parseMultiString(`"""`)
+	case strings.HasPrefix(p.s[p.pos:], "'''"):
+		return p.parseMultiString("'''")
+	case c == '"':
+		return p.parseBasicString()
+	case c == '\'':
+		return p.parseLiteralString()
+	case c == '[':
+		return p.parseArray()
+	case c == '{':
+		return p.parseInlineTable()
+	}
+
+	start := p.pos
+	for !p.eof() && !strings.ContainsRune(" \t\n#,]}", rune(p.s[p.pos])) {
+		p.pos++
+	}
+	// the datetime "1979-05-27 07:32:00" with a space
+	if p.pos-start == 10 && strings.Count(p.s[start:p.pos], "-") == 2 &&
+		p.pos+3 < len(p.s) && p.s[p.pos] == ' ' && isDigit(p.s[p.pos+1]) && isDigit(p.s[p.pos+2]) && p.s[p.pos+3] == ':' {
+		p.pos++
+		for !p.eof() && !strings.ContainsRune(" \t\n#,]}", rune(p.s[p.pos])) {
+			p.pos++
+		}
+	}
+	text := p.s[start:p.pos]
+	switch text {
+	case "true", "false", "inf", "+inf", "-inf", "nan", "+nan", "-nan":
+		return text, nil
+	case "":
+		return nil, p.errorf("missing value")
+	}
+	if !isDigit(text[0]) && text[0] != '+' && text[0] != '-' {
+		return nil, p.errorf("invalid value %q", text)
+	}
+	if strings.Contains(text, ":") || strings.Count(text, "-") >= 2 && !strings.ContainsAny(text, "eE") {
+		// datetime, date or time
+		return text, nil
+	}
+	if strings.HasSuffix(text, "_") || strings.Contains(text, "__") {
+		return nil, p.errorf("invalid number %q", text)
+	}
+	num := strings.Replace(text, "_", "", -1)
+	if i, err := strconv.ParseInt(strings.TrimPrefix(num, "+"), 0, 64); err == nil {
+		if len(num) > 1 && num[0] == '0' && isDigit(num[1]) {
+			return nil, p.errorf("leading zeros are not allowed %q", text)
+		}
+		return strconv.FormatInt(i, 10), nil
+	}
+	if _, err := strconv.ParseFloat(num, 64); err == nil && !strings.HasPrefix(num, "0x") {
+		return num, nil
+	}
+	return nil, p.errorf("invalid value %q", text)
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func (p *tomlParser) parseBasicString() (string, error) {
+	p.pos++
+	var sb strings.Builder
+	for {
+		if p.eof() || p.peek() == '\n' {
+			return "", p.errorf("unterminated string")
+		}
+		c := p.s[p.pos]
+		switch c {
+		case '"':
+			p.pos++
+			return sb.String(), nil
+		case '\\':
+			if err := p.parseEscape(&sb); err != nil {
+				return "", err
+			}
+		default:
+			sb.WriteByte(c)
+			p.pos++
+		}
+	}
+}
+
+// parseEscape parse the escape sequence at pos
+func (p *tomlParser) parseEscape(sb *strings.Builder) error {
+	p.pos++
+	if p.eof() {
+		return p.errorf("unterminated string")
+	}
+	c := p.s[p.pos]
+	p.pos++
+	switch c {
+	case 'b':
+		sb.WriteByte('\b')
+	case 't':
+		sb.WriteByte('\t')
+	case 'n':
+		sb.WriteByte('\n')
+	case 'f':
+		sb.WriteByte('\f')
+	case 'r':
+		sb.WriteByte('\r')
+	case 'e':
+		sb.WriteByte('\x1b')
+	case '"', '\\':
+		sb.WriteByte(c)
+	case 'u', 'U':
+		n := 4
+		if c == 'U' {
+			n = 8
+		}
+		if p.pos+n > len(p.s) {
+			return p.errorf("invalid unicode escape")
+		}
+		r, err := strconv.ParseUint(p.s[p.pos:p.pos+n], 16, 32)
+		if err != nil || !utf8.ValidRune(rune(r)) {
+			return p.errorf("invalid unicode escape %q", p.s[p.pos:p.pos+n])
+		}
+		sb.WriteRune(rune(r))
+		p.pos += n
+	default:
+		return p.errorf("invalid escape \\%c", c)
+	}
+	return nil
+}
+
+func (p *tomlParser) parseLiteralString() (string, error) {
+	p.pos++
+	end := strings.IndexAny(p.s[p.pos:], "'\n")
+	if end < 0 || p.s[p.pos+end] != '\'' {
+		return "", p.errorf("unterminated string")
+	}
+	s := p.s[p.pos : p.pos+end]
+	p.pos += end + 1
+	return s, nil
+}
+
+// parseMultiString parse the multi-line basic or literal string, the newline
+// right after the opening quotes is trimmed
+func (p *tomlParser) parseMultiString(quote string) (string, error) {
+	p.pos += 3
+	if p.peek() == '\n' {
+		p.pos++
+		p.line++
+	}
+	var sb strings.Builder
+	for {
+		if p.eof() {
+			return "", p.errorf("unterminated string")
+		}
+		if strings.HasPrefix(p.s[p.pos:], quote) {
+			p.pos += 3
+			// up to 2 quotes are allowed before the closing ones
+			for i := 0; i < 2 && p.peek() == quote[0]; i++ {
+				sb.WriteByte(quote[0])
+				p.pos++
+			}
+			return sb.String(), nil
+		}
+		c := p.s[p.pos]
+		if c == '\\' && quote == `"""` {
+			// line ending backslash trims the whitespace to the next text
+			j := p.pos + 1
+			for j < len(p.s) && (p.s[j] == ' ' || p.s[j] == '\t') {
+				j++
+			}
+			if j < len(p.s) && p.s[j] == '\n' {
+				for j < len(p.s) && strings.ContainsRune(" \t\n", rune(p.s[j])) {
+					if p.s[j] == '\n' {
+						p.line++
+					}
+					j++
+				}
+				p.pos = j
+				continue
+			}
+			if err := p.parseEscape(&sb); err != nil {
+				return "", err
+			}
+			continue
+		}
+		if c == '\n' {
+			p.line++
+		}
+		sb.WriteByte(c)
+		p.pos++
+	}
+}
+
+// parseArray parse [a, b], it may span lines
+func (p *tomlParser) parseArray() ([]interface{}, error) {
+	p.pos++
+	arr := []interface{}{}
+	for {
+		p.skipBlank()
+		if p.peek() == ']' {
+			p.pos++
+			return arr, nil
+		}
+		v, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		arr = append(arr, v)
+		p.skipBlank()
+		switch p.peek() {
+		case ',':
+			p.pos++
+		case ']':
+		default:
+			return nil, p.errorf("expected ',' or ']' in array")
+		}
+	}
+}
+
+// parseInlineTable parse {a = 1, b = 2} in one line
+func (p *tomlParser) parseInlineTable() (map[string]interface{}, error) {
+	p.pos++
+	t := map[string]interface{}{}
+	p.skipSpace()
+	if p.peek() == '}' {
+		p.pos++
+		return t, nil
+	}
+	for {
+		if err := p.parseKeyValue(t); err != nil {
+			return nil, err
+		}
+		p.skipSpace()
+		switch p.peek() {
+		case ',':
+			p.pos++
+			p.skipSpace()
+		case '}':
+			p.pos++
+			return t, nil
+		default:
+			return nil, p.errorf("expected ',' or '}' in inline table")
+		}
+	}
+}
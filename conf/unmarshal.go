@@ -0,0 +1,137 @@
+package conf
+
+import (
+	"encoding"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+var (
+	timeType          = reflect.TypeOf(time.Time{})
+	textUnmarshalType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// unmarshal set the fields of the struct rv, the fields tagged with a key
+// only appear in section. It returns whether any field is set. The stack
+// is the nested struct types to stop the recursive types.
+func (c *Config) unmarshal(rv reflect.Value, section, flag string, stack []reflect.Type) (bool, error) {
+	rt := rv.Type()
+	stack = append(stack, rt)
+	set := false
+	for i := 0; i < rv.NumField(); i++ {
+		vf := rv.Field(i)
+		tf := rt.Field(i)
+		tag := tf.Tag.Get(flag)
+		if tag == "-" || (tf.PkgPath != "" && !tf.Anonymous) {
+			continue
+		}
+		if tag == "" || tag == "omitempty" {
+			if !isNested(tf.Type) || inStack(stack, tf.Type) {
+				continue
+			}
+			ok, err := c.unmarshalNested(vf, joinSection(section, tf.Tag.Get("section")), flag, stack)
+			if err != nil {
+				return set, err
+			}
+			set = set || ok
+			continue
+		}
+		if !vf.CanSet() {
+			continue
+		}
+
+		tagArr, err := fieldTag(tag, section)
+		if err != nil {
+			return set, err
+		}
+		value, ok := c.value(tagArr[0], tagArr[1])
+		if !ok {
+			if value, ok = tf.Tag.Lookup("default"); !ok {
+				continue
+			}
+		}
+		if err := c.getVal(&vf, &tf, tagArr, value); err != nil {
+			return set, err
+		}
+		set = true
+	}
+	return set, nil
+}
+
+// fieldTag split the tag into section, key and the optional option, the
+// section is used if the tag has no section
+func fieldTag(tag, section string) ([]string, error) {
+	tagArr := strings.SplitN(tag, ":", 3)
+	switch {
+	case len(tagArr) == 1 && section != "":
+		tagArr = []string{section, tag}
+	case len(tagArr) < 2:
+		return nil, fmt.Errorf("error tag: %s, must be section:field:delim(optional)", tag)
+	case tagArr[0] == "":
+		tagArr[0] = section
+	}
+	return tagArr, nil
+}
+
+// unmarshalNested unmarshal the nested struct, the nil pointer is set only
+// if any field is set
+func (c *Config) unmarshalNested(vf reflect.Value, section, flag string, stack []reflect.Type) (bool, error) {
+	if vf.Kind() != reflect.Ptr {
+		return c.unmarshal(vf, section, flag, stack)
+	}
+	if !vf.IsNil() {
+		return c.unmarshal(vf.Elem(), section, flag, stack)
+	}
+	if !vf.CanSet() {
+		return false, nil
+	}
+	nv := reflect.New(vf.Type().Elem())
+	ok, err := c.unmarshal(nv.Elem(), section, flag, stack)
+	if ok {
+		vf.Set(nv)
+	}
+	return ok, err
+}
+
+// value return the value of the key in section
+func (c *Config) value(section, key string) (string, bool) {
+	s := c.Get(section)
+	if s == nil {
+		return "", false
+	}
+	val, ok := s.val[key]
+	return val, ok
+}
+
+// isNested return whether t is a struct or a struct pointer unmarshaled
+// field by field
+func isNested(t reflect.Type) bool {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t.Kind() == reflect.Struct && t != timeType && !reflect.PtrTo(t).Implements(textUnmarshalType)
+}
+
+func inStack(stack []reflect.Type, t reflect.Type) bool {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	for _, st := range stack {
+		if st == t {
+			return true
+		}
+	}
+	return false
+}
+
+func joinSection(section, sub string) string {
+	switch {
+	case sub == "":
+		return section
+	case section == "":
+		return sub
+	}
+	return section + "." + sub
+}
@@ -0,0 +1,106 @@
+package conf
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+type level int
+
+func (l *level) UnmarshalText(text []byte) error {
+	switch string(text) {
+	case "debug":
+		*l = 1
+	case "info":
+		*l = 2
+	default:
+		return fmt.Errorf("unknown level %s", text)
+	}
+	return nil
+}
+
+type replica struct {
+	Host string `json:"host"`
+}
+
+type dbOption struct {
+	Host    string   `json:"host"`
+	Port    *int     `json:"port" default:"3306"`
+	Replica *replica `section:"replica"`
+	Backup  *replica `section:"backup"`
+}
+
+type common struct {
+	Name string `json:"app:name"`
+}
+
+type option struct {
+	common
+	DB      dbOption      `section:"db"`
+	Level   level         `json:"log:level"`
+	LevelP  *level        `json:"log:level_p"`
+	Start   time.Time     `json:"app:start"`
+	Day     time.Time     `json:"app:day:2006-01-02"`
+	Timeout time.Duration `json:"app:timeout:time" default:"3s"`
+	Tags    *[]string     `json:"app:tags"`
+	Missing *string       `json:"app:missing"`
+	Self    *option
+}
+
+func TestUnmarshalNested(t *testing.T) {
+	c := New()
+	err := c.ParseReader(strings.NewReader(`
+[app]
+name = ego
+start = 2020-01-02T03:04:05Z
+day = 2020-01-02
+tags = a,b
+[db]
+host = master
+[db.replica]
+host = replica
+[log]
+level = info
+level_p = info
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var v option
+	if err = c.Unmarshal(&v, "json"); err != nil {
+		t.Fatal(err)
+	}
+	if v.Name != "ego" || v.DB.Host != "master" || v.DB.Port == nil || *v.DB.Port != 3306 {
+		t.Errorf("option = %+v", v)
+	}
+	if v.DB.Replica == nil || v.DB.Replica.Host != "replica" || v.DB.Backup != nil {
+		t.Errorf("replica = %+v, backup = %+v", v.DB.Replica, v.DB.Backup)
+	}
+	if v.Level != 2 || v.LevelP == nil || *v.LevelP != 2 {
+		t.Errorf("level = %v %v", v.Level, v.LevelP)
+	}
+	if !v.Start.Equal(time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)) || v.Day.Day() != 2 {
+		t.Errorf("start = %v, day = %v", v.Start, v.Day)
+	}
+	if v.Timeout != 3*time.Second || v.Tags == nil || len(*v.Tags) != 2 || v.Missing != nil || v.Self != nil {
+		t.Errorf("option = %+v", v)
+	}
+
+	var db dbOption
+	if err = c.UnmarshalSection(&db, "db.replica", "json"); err != nil {
+		t.Fatal(err)
+	}
+	if db.Host != "replica" || db.Replica != nil {
+		t.Errorf("db = %+v", db)
+	}
+
+	var bad struct {
+		Level level `json:"db:host"`
+	}
+	if err = c.Unmarshal(&bad, "json"); err == nil || !strings.Contains(err.Error(), "Level") {
+		t.Errorf("err = %v", err)
+	}
+}
@@ -0,0 +1,366 @@
+package conf
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FieldError a config value failed a validation rule
+type FieldError struct {
+	Section string
+	Key     string
+	// where the value is from, such as "app.conf:12" or "env", see Source
+	Pos   string
+	Field string
+	Rule  string
+	Msg   string
+}
+
+func (e *FieldError) Error() string {
+	name := e.Key
+	switch {
+	case e.Key == "":
+		name = e.Section
+	case e.Section != "":
+		name = e.Section + ":" + e.Key
+	}
+	if e.Pos != "" {
+		return fmt.Sprintf("%s (%s) %s", name, e.Pos, e.Msg)
+	}
+	return fmt.Sprintf("%s %s", name, e.Msg)
+}
+
+// ValidationErrors all the problems of the config, one per line
+type ValidationErrors []error
+
+func (es ValidationErrors) Error() string {
+	msgs := make([]string, len(es))
+	for i, e := range es {
+		msgs[i] = e.Error()
+	}
+	return strings.Join(msgs, "\n")
+}
+
+// Validate check the fields of the struct pointed to by v, which is
+// unmarshaled by Unmarshal with the same flag, by the rules of the
+// "validate" tag separated by ",":
+//
+//	required     the value is not empty
+//	min=n        the number is at least n, or the length of the string,
+//	             slice or map, n may be a duration such as "1s"
+//	max=n        the number is at most n, or the length
+//	oneof=a b c  the value is one of the values separated by space
+//	url          the value is an absolute url
+//	hostport     the value is "host:port", the host may be empty
+//	file_exists  the file exists
+//
+// The rules except required are skipped if the value is empty, and the
+// rules of a string slice are applied to each element. All the problems
+// are returned at once as ValidationErrors.
+//
+//	type HTTPOption struct {
+//		Addr    string        `json:"http_conf:addr" validate:"required,hostport"`
+//		Timeout time.Duration `json:"http_conf:timeout:time" validate:"min=1s,max=1m"`
+//		Mode    string        `json:"http_conf:mode" validate:"oneof=debug release"`
+//	}
+func (c *Config) Validate(v interface{}, flag string) error {
+	vv := reflect.ValueOf(v)
+	if vv.Kind() != reflect.Ptr || vv.IsNil() || vv.Elem().Kind() != reflect.Struct {
+		return &InvalidUnmarshalError{reflect.TypeOf(v)}
+	}
+	var errs ValidationErrors
+	c.validate(vv.Elem(), "", flag, nil, &errs)
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func (c *Config) validate(rv reflect.Value, section, flag string, stack []reflect.Type, errs *ValidationErrors) {
+	rt := rv.Type()
+	stack = append(stack, rt)
+	for i := 0; i < rv.NumField(); i++ {
+		vf := rv.Field(i)
+		tf := rt.Field(i)
+		tag := tf.Tag.Get(flag)
+		if tag == "-" || (tf.PkgPath != "" && !tf.Anonymous) {
+			continue
+		}
+		if tag == "" || tag == "omitempty" {
+			if !isNested(tf.Type) || inStack(stack, tf.Type) {
+				continue
+			}
+			sub := joinSection(section, tf.Tag.Get("section"))
+			if vf.Kind() == reflect.Ptr {
+				if vf.IsNil() {
+					if hasRule(tf.Tag.Get("validate"), "required") {
+						*errs = append(*errs, &FieldError{Section: sub, Field: tf.Name, Rule: "required", Msg: "section is required"})
+					}
+					continue
+				}
+				vf = vf.Elem()
+			}
+			c.validate(vf, sub, flag, stack, errs)
+			continue
+		}
+
+		rules := tf.Tag.Get("validate")
+		if rules == "" {
+			continue
+		}
+		tagArr, err := fieldTag(tag, section)
+		if err != nil {
+			*errs = append(*errs, err)
+			continue
+		}
+		for _, rule := range strings.Split(rules, ",") {
+			name, arg := rule, ""
+			if idx := strings.Index(rule, "="); idx >= 0 {
+				name, arg = rule[:idx], rule[idx+1:]
+			}
+			msg := checkRule(vf, name, arg, c.IsSecret(tagArr[0], tagArr[1]))
+			if msg == "" {
+				continue
+			}
+			*errs = append(*errs, &FieldError{
+				Section: tagArr[0],
+				Key:     tagArr[1],
+				Pos:     c.position(tagArr[0], tagArr[1]),
+				Field:   tf.Name,
+				Rule:    rule,
+				Msg:     msg,
+			})
+			if name == "required" {
+				break
+			}
+		}
+	}
+}
+
+func hasRule(rules, name string) bool {
+	for _, rule := range strings.Split(rules, ",") {
+		if rule == name {
+			return true
+		}
+	}
+	return false
+}
+
+// checkRule return the problem of v by the rule, "" if it's valid, the
+// secret value is masked in the problem
+func checkRule(v reflect.Value, name, arg string, secret bool) string {
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			v = reflect.Zero(v.Type().Elem())
+			break
+		}
+		v = v.Elem()
+	}
+	if name == "required" {
+		if v.IsZero() {
+			return "is required"
+		}
+		return ""
+	}
+	if v.IsZero() {
+		return ""
+	}
+
+	switch name {
+	case "min", "max":
+		n, bound, err := compareValues(v, arg)
+		if err != nil {
+			return fmt.Sprintf("rule %s=%s: %v", name, arg, err)
+		}
+		switch {
+		case name == "min" && n < bound:
+			return "must be at least " + arg
+		case name == "max" && n > bound:
+			return "must be at most " + arg
+		}
+		return ""
+	case "oneof":
+		val := fmt.Sprint(v.Interface())
+		for _, s := range strings.Fields(arg) {
+			if s == val {
+				return ""
+			}
+		}
+		return fmt.Sprintf("must be one of [%s], got %s", arg, quote(val, secret))
+	case "url", "hostport", "file_exists":
+		vals, ok := stringValues(v)
+		if !ok {
+			return fmt.Sprintf("rule %s is not supported by %s", name, v.Type())
+		}
+		for _, val := range vals {
+			if msg := checkString(name, val, secret); msg != "" {
+				return msg
+			}
+		}
+		return ""
+	}
+	return "unknown rule " + name
+}
+
+func checkString(name, val string, secret bool) string {
+	switch name {
+	case "url":
+		if u, err := url.Parse(val); err != nil || u.Scheme == "" || u.Host == "" {
+			return fmt.Sprintf("must be an absolute url, got %s", quote(val, secret))
+		}
+	case "hostport":
+		_, port, err := net.SplitHostPort(val)
+		if err == nil {
+			_, err = strconv.ParseUint(port, 10, 16)
+		}
+		if err != nil {
+			return fmt.Sprintf("must be host:port, got %s", quote(val, secret))
+		}
+	case "file_exists":
+		if _, err := os.Stat(val); err != nil {
+			return fmt.Sprintf("file %s does not exist", quote(val, secret))
+		}
+	}
+	return ""
+}
+
+func quote(val string, secret bool) string {
+	if secret {
+		return Mask
+	}
+	return strconv.Quote(val)
+}
+
+// compareValues return the number or the length of v and the bound
+func compareValues(v reflect.Value, arg string) (float64, float64, error) {
+	var n float64
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n = float64(v.Int())
+		if v.Kind() == reflect.Int64 {
+			// the durations tagged "time" are int64
+			if d, err := time.ParseDuration(arg); err == nil {
+				return n, float64(d), nil
+			}
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n = float64(v.Uint())
+	case reflect.Float32, reflect.Float64:
+		n = v.Float()
+	case reflect.String, reflect.Slice, reflect.Map, reflect.Array:
+		n = float64(v.Len())
+	default:
+		return 0, 0, fmt.Errorf("not supported by %s", v.Type())
+	}
+	bound, err := strconv.ParseFloat(arg, 64)
+	return n, bound, err
+}
+
+// stringValues return the string or the elements of the string slice
+func stringValues(v reflect.Value) ([]string, bool) {
+	switch {
+	case v.Kind() == reflect.String:
+		return []string{v.String()}, true
+	case v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.String:
+		vals := make([]string, v.Len())
+		for i := range vals {
+			vals[i] = v.Index(i).String()
+		}
+		return vals, true
+	}
+	return nil, false
+}
+
+type schema struct {
+	typ  reflect.Type
+	flag string
+}
+
+var schemas []schema
+
+// RegisterSchema register the struct of v to be checked by Check, it's
+// unmarshaled and validated by the flag ("json" by default)
+//
+//	conf.RegisterSchema(&HTTPOption{})
+func RegisterSchema(v interface{}, flag ...string) {
+	t := reflect.TypeOf(v)
+	if t == nil || t.Kind() != reflect.Ptr || t.Elem().Kind() != reflect.Struct {
+		panic(&InvalidUnmarshalError{t})
+	}
+	f := "json"
+	if len(flag) > 0 {
+		f = flag[0]
+	}
+	subsMu.Lock()
+	defer subsMu.Unlock()
+	schemas = append(schemas, schema{t.Elem(), f})
+}
+
+var knownSections = map[string]bool{}
+
+// RegisterSection declare the sections read without a schema, so they're
+// not unknown sections in strict mode
+//
+//	conf.RegisterSection("redis")
+func RegisterSection(names ...string) {
+	subsMu.Lock()
+	defer subsMu.Unlock()
+	for _, name := range names {
+		knownSections[name] = true
+	}
+}
+
+// Check unmarshal and validate the registered schemas, then run the
+// validators added by OnValidate. All the problems are returned at once
+// as ValidationErrors.
+func (c *Config) Check() error {
+	subsMu.Lock()
+	ss := append([]schema{}, schemas...)
+	vs := append([]func(c *Config) error{}, validators...)
+	subsMu.Unlock()
+
+	var errs ValidationErrors
+	for _, s := range ss {
+		v := reflect.New(s.typ).Interface()
+		if err := c.Unmarshal(v, s.flag); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %v", s.typ, err))
+			continue
+		}
+		if err := c.Validate(v, s.flag); err != nil {
+			errs = append(errs, err.(ValidationErrors)...)
+		}
+	}
+	for _, validate := range vs {
+		if err := validate(c); err != nil {
+			if es, ok := err.(ValidationErrors); ok {
+				errs = append(errs, es...)
+			} else {
+				errs = append(errs, err)
+			}
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// Validate validate v by the global config, see Config.Validate
+func Validate(v interface{}, flag ...string) error {
+	f := "json"
+	if len(flag) > 0 {
+		f = flag[0]
+	}
+	return global().Validate(v, f)
+}
+
+// Check check the global config, see Config.Check
+func Check() error {
+	return global().Check()
+}
@@ -0,0 +1,114 @@
+package conf
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+type httpSchema struct {
+	Addr     string        `json:"http_conf:addr" validate:"required,hostport"`
+	Timeout  time.Duration `json:"http_conf:timeout:time" validate:"min=1s,max=1m"`
+	Mode     string        `json:"http_conf:mode" validate:"oneof=debug release"`
+	Workers  int           `json:"http_conf:workers" validate:"min=1,max=64"`
+	Callback string        `json:"http_conf:callback" validate:"url"`
+	Hosts    []string      `json:"http_conf:hosts" validate:"max=2,hostport"`
+	TLS      *struct {
+		Cert string `json:"cert" validate:"required,file_exists"`
+	} `section:"tls"`
+	DB *struct {
+		DSN string `json:"dsn" validate:"required"`
+	} `section:"db" validate:"required"`
+}
+
+func TestValidate(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "app.conf")
+	err := os.WriteFile(file, []byte(`[http_conf]
+addr = localhost
+timeout = 2m
+mode = test
+workers = 8
+callback = /path
+hosts = a:1,b:2,c
+[tls]
+cert = `+filepath.Join(dir, "missing.pem")+`
+`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := New()
+	if err = c.Parse(file); err != nil {
+		t.Fatal(err)
+	}
+	c.Resolve([]string{"EGO_HTTP_CONF_WORKERS=100"}, nil)
+
+	var v httpSchema
+	if err = c.Unmarshal(&v, "json"); err != nil {
+		t.Fatal(err)
+	}
+	err = c.Validate(&v, "json")
+	errs, ok := err.(ValidationErrors)
+	if !ok {
+		t.Fatalf("err = %v", err)
+	}
+	want := []string{
+		"http_conf:addr (" + file + ":2) must be host:port",
+		"http_conf:timeout (" + file + ":3) must be at most 1m",
+		"http_conf:mode (" + file + ":4) must be one of [debug release]",
+		"http_conf:workers (env) must be at most 64",
+		"http_conf:callback (" + file + ":6) must be an absolute url",
+		"http_conf:hosts (" + file + ":7) must be at most 2",
+		"http_conf:hosts (" + file + ":7) must be host:port",
+		"tls:cert (" + file + ":9) file",
+		"db section is required",
+	}
+	if len(errs) != len(want) {
+		t.Fatalf("errs = %v", err)
+	}
+	for i, e := range errs {
+		if !strings.HasPrefix(e.Error(), want[i]) {
+			t.Errorf("err %d = %v, want %v", i, e, want[i])
+		}
+	}
+	if fe := errs[0].(*FieldError); fe.Field != "Addr" || fe.Rule != "hostport" {
+		t.Errorf("field error = %+v", fe)
+	}
+}
+
+func TestCheck(t *testing.T) {
+	subsMu.Lock()
+	oldSchemas, oldValidators := schemas, validators
+	subsMu.Unlock()
+	defer func() {
+		subsMu.Lock()
+		schemas, validators = oldSchemas, oldValidators
+		subsMu.Unlock()
+	}()
+
+	type schema struct {
+		Port int `json:"http_conf:port" validate:"required"`
+	}
+	RegisterSchema(&schema{})
+	c := New()
+	if err := c.ParseReader(strings.NewReader("[http_conf]\nport = 8080\n")); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Check(); err != nil {
+		t.Fatal(err)
+	}
+
+	c = New()
+	if err := c.ParseReader(strings.NewReader("[http_conf]\nport = x\n")); err != nil {
+		t.Fatal(err)
+	}
+	OnValidate(func(c *Config) error {
+		return &FieldError{Key: "custom", Msg: "is invalid"}
+	})
+	errs, ok := c.Check().(ValidationErrors)
+	if !ok || len(errs) != 2 || !strings.Contains(errs[0].Error(), "invalid syntax") || errs[1].Error() != "custom is invalid" {
+		t.Errorf("errs = %v", errs)
+	}
+}
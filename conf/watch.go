@@ -0,0 +1,316 @@
+package conf
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ChangeType the type of a config change
+type ChangeType int
+
+const (
+	Added ChangeType = iota + 1
+	Removed
+	Modified
+)
+
+func (t ChangeType) String() string {
+	switch t {
+	case Added:
+		return "added"
+	case Removed:
+		return "removed"
+	case Modified:
+		return "modified"
+	}
+	return "unknown"
+}
+
+// Change a changed key, Section is "" for the common config
+type Change struct {
+	Section string
+	Key     string
+	Type    ChangeType
+	Old     string
+	New     string
+	// the old or new value is secret, it's masked by String
+	Secret bool
+}
+
+func (c Change) String() string {
+	name := keyName(c.Section, c.Key)
+	old, new := c.Old, c.New
+	if c.Secret {
+		old, new = Mask, Mask
+	}
+	switch c.Type {
+	case Added:
+		return fmt.Sprintf("+ %s = %s", name, new)
+	case Removed:
+		return fmt.Sprintf("- %s = %s", name, old)
+	}
+	return fmt.Sprintf("~ %s = %s -> %s", name, old, new)
+}
+
+// Diff return the changed keys from old to new, sorted by section and key
+func Diff(old, new *Config) []Change {
+	var changes []Change
+	changes = diffKeys(changes, "", old.Common, new.Common)
+
+	sections := map[string]bool{}
+	for name := range old.Sector {
+		sections[name] = true
+	}
+	for name := range new.Sector {
+		sections[name] = true
+	}
+	for name := range sections {
+		var oldVal, newVal map[string]string
+		if s := old.Get(name); s != nil {
+			oldVal = s.val
+		}
+		if s := new.Get(name); s != nil {
+			newVal = s.val
+		}
+		changes = diffKeys(changes, name, oldVal, newVal)
+	}
+
+	for i := range changes {
+		ch := &changes[i]
+		ch.Secret = old.IsSecret(ch.Section, ch.Key) || new.IsSecret(ch.Section, ch.Key)
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		if changes[i].Section != changes[j].Section {
+			return changes[i].Section < changes[j].Section
+		}
+		return changes[i].Key < changes[j].Key
+	})
+	return changes
+}
+
+// DiffFile parse file with the defaults, the environment variables and the
+// flags of c, return the changes from c to it
+func (c *Config) DiffFile(file string) ([]Change, error) {
+	nc, err := c.reparse(file)
+	if err != nil {
+		return nil, err
+	}
+	return Diff(c, nc), nil
+}
+
+// DiffFile return the changes from the global config to file, see
+// Config.DiffFile
+func DiffFile(file string) ([]Change, error) {
+	return global().DiffFile(file)
+}
+
+func diffKeys(changes []Change, section string, old, new map[string]string) []Change {
+	for k, o := range old {
+		if n, ok := new[k]; !ok {
+			changes = append(changes, Change{Section: section, Key: k, Type: Removed, Old: o})
+		} else if n != o {
+			changes = append(changes, Change{Section: section, Key: k, Type: Modified, Old: o, New: n})
+		}
+	}
+	for k, n := range new {
+		if _, ok := old[k]; !ok {
+			changes = append(changes, Change{Section: section, Key: k, Type: Added, New: n})
+		}
+	}
+	return changes
+}
+
+type subscriber struct {
+	section string
+	fn      func(old, new *Section)
+}
+
+var (
+	// reloadMu serialize Reload
+	reloadMu sync.Mutex
+
+	subsMu      sync.Mutex
+	subscribers []subscriber
+	validators  []func(c *Config) error
+)
+
+// OnChange subscribe the changes of the section by Reload, old or new is
+// nil if the section is added or removed
+//
+//	conf.OnChange("log", func(old, new *conf.Section) {
+//		level, _ := new.String("level")
+//		...
+//	})
+func OnChange(section string, fn func(old, new *Section)) {
+	subsMu.Lock()
+	defer subsMu.Unlock()
+	subscribers = append(subscribers, subscriber{section, fn})
+}
+
+// OnValidate add a validator run by Check, the reloaded config is not
+// swapped in if any validator or registered schema failed
+func OnValidate(fn func(c *Config) error) {
+	subsMu.Lock()
+	defer subsMu.Unlock()
+	validators = append(validators, fn)
+}
+
+// Reload reparse the files of the global config, the new config is swapped
+// in only if it's parsed and checked by Check, then the subscribers of the changed
+// sections are notified. It returns the changes.
+func Reload() ([]Change, error) {
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+
+	old := global()
+	if old.File == "" {
+		return nil, fmt.Errorf("conf is not initialized")
+	}
+	nc, err := old.Reload()
+	if err != nil {
+		return nil, err
+	}
+
+	if err = nc.Check(); err != nil {
+		return nil, err
+	}
+	subsMu.Lock()
+	subs := append([]subscriber{}, subscribers...)
+	subsMu.Unlock()
+
+	changes := Diff(old, nc)
+	gconf.Store(nc)
+
+	changed := map[string]bool{}
+	for _, c := range changes {
+		changed[c.Section] = true
+	}
+	for _, sub := range subs {
+		if changed[sub.section] {
+			sub.fn(old.Get(sub.section), nc.Get(sub.section))
+		}
+	}
+	return changes, nil
+}
+
+// WatchOptions config watcher options
+type WatchOptions struct {
+	// polling interval of the files (default: 5s)
+	Interval time.Duration
+	// called with the changes after reloaded
+	OnReload func(changes []Change)
+	// called if failed to reload, the error is printed to stderr by default
+	OnError func(err error)
+}
+
+// Watch poll the files of the global config including the included ones,
+// and Reload if any of them is modified. It returns a function to stop.
+func Watch(op ...WatchOptions) (stop func()) {
+	opt := WatchOptions{
+		Interval: 5 * time.Second,
+		OnError: func(err error) {
+			fmt.Fprintf(os.Stderr, "conf reload err:%v\n", err)
+		},
+	}
+	if len(op) > 0 {
+		if op[0].Interval > 0 {
+			opt.Interval = op[0].Interval
+		}
+		if op[0].OnError != nil {
+			opt.OnError = op[0].OnError
+		}
+		opt.OnReload = op[0].OnReload
+	}
+
+	done := make(chan struct{})
+	c := global()
+	stats := statFiles(c.watchFiles())
+	go func() {
+		ticker := time.NewTicker(opt.Interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+
+			if now := statFiles(c.watchFiles()); !now.equal(stats) {
+				// the failed version is not retried until modified again
+				stats = now
+				changes, err := watchReload()
+				if err != nil {
+					opt.OnError(err)
+					continue
+				}
+				c = global()
+				stats = statFiles(c.watchFiles())
+				if opt.OnReload != nil && len(changes) > 0 {
+					opt.OnReload(changes)
+				}
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() { close(done) })
+	}
+}
+
+// watchReload reload and recover the panic of the subscribers
+func watchReload() (changes []Change, err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("conf subscriber panic: %v", p)
+		}
+	}()
+	return Reload()
+}
+
+// watchFiles return the parsed files and the files matching the include
+// globs now, so a new file of conf.d/*.conf is found
+func (c *Config) watchFiles() []string {
+	files := append([]string(nil), c.files...)
+	for _, pattern := range c.globs {
+		matches, _ := filepath.Glob(pattern)
+		files = append(files, matches...)
+	}
+	return files
+}
+
+type fileStat struct {
+	modTime time.Time
+	size    int64
+	exist   bool
+}
+
+type fileStats map[string]fileStat
+
+func statFiles(files []string) fileStats {
+	stats := make(fileStats, len(files))
+	for _, f := range files {
+		if fi, err := os.Stat(f); err == nil {
+			stats[f] = fileStat{fi.ModTime(), fi.Size(), true}
+		} else {
+			stats[f] = fileStat{}
+		}
+	}
+	return stats
+}
+
+func (s fileStats) equal(o fileStats) bool {
+	if len(s) != len(o) {
+		return false
+	}
+	for f, st := range s {
+		if ot, ok := o[f]; !ok || !ot.modTime.Equal(st.modTime) || ot.size != st.size || ot.exist != st.exist {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,121 @@
+package conf
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDiff(t *testing.T) {
+	old, nc := New(), New()
+	old.ParseReader(strings.NewReader("a=1\nb=2\n[s]\nx=1\ny=2\n[gone]\nk=v\n"))
+	nc.ParseReader(strings.NewReader("a=1\nb=3\n[s]\nx=1\nz=3\n[new]\nk=v\n"))
+
+	got := Diff(old, nc)
+	want := []string{"~ b = 2 -> 3", "- gone:k = v", "+ new:k = v", "- s:y = 2", "+ s:z = 3"}
+	if len(got) != len(want) {
+		t.Fatalf("diff = %v", got)
+	}
+	for i := range want {
+		if got[i].String() != want[i] {
+			t.Errorf("diff[%d] = %v, want %v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestWatch(t *testing.T) {
+	defer gconf.Store(global())
+	subsMu.Lock()
+	oldSubs, oldValidators := subscribers, validators
+	subsMu.Unlock()
+	defer func() {
+		subsMu.Lock()
+		subscribers, validators = oldSubs, oldValidators
+		subsMu.Unlock()
+	}()
+
+	file := filepath.Join(t.TempDir(), "app.conf")
+	os.WriteFile(file, []byte("[log]\nlevel=4\n[http_conf]\nport=8080\n"), 0644)
+	Init(file)
+
+	type event struct{ old, new string }
+	events := make(chan event, 4)
+	OnChange("log", func(old, new *Section) {
+		o, _ := old.String("level")
+		n, _ := new.String("level")
+		events <- event{o, n}
+	})
+	OnValidate(func(c *Config) error {
+		if _, err := c.Get("http_conf").Uint("port"); err != nil {
+			return err
+		}
+		return nil
+	})
+
+	errs := make(chan error, 4)
+	stop := Watch(WatchOptions{Interval: 10 * time.Millisecond, OnError: func(err error) { errs <- err }})
+	defer stop()
+
+	// invalid port is rejected, the old config is kept
+	writeLater(file, "[log]\nlevel=2\n[http_conf]\nport=abc\n")
+	select {
+	case <-errs:
+	case <-time.After(2 * time.Second):
+		t.Fatal("invalid config not rejected")
+	}
+	if level, _ := Get("log").String("level"); level != "4" {
+		t.Errorf("level = %v after rejected reload", level)
+	}
+
+	writeLater(file, "[log]\nlevel=3\n[http_conf]\nport=8080\n")
+	select {
+	case e := <-events:
+		if e.old != "4" || e.new != "3" {
+			t.Errorf("event = %+v", e)
+		}
+	case err := <-errs:
+		t.Fatal(err)
+	case <-time.After(2 * time.Second):
+		t.Fatal("change not notified")
+	}
+	if level, _ := Get("log").String("level"); level != "3" {
+		t.Errorf("level = %v after reload", level)
+	}
+}
+
+func TestWatchGlob(t *testing.T) {
+	defer gconf.Store(global())
+
+	dir := t.TempDir()
+	file := filepath.Join(dir, "app.conf")
+	os.WriteFile(file, []byte("include conf.d/*.conf\n[log]\nlevel=4\n"), 0644)
+	os.Mkdir(filepath.Join(dir, "conf.d"), 0755)
+	Init(file)
+
+	reloaded := make(chan []Change, 4)
+	stop := Watch(WatchOptions{Interval: 10 * time.Millisecond, OnReload: func(changes []Change) { reloaded <- changes }})
+	defer stop()
+
+	// a new file matching the glob is included
+	os.WriteFile(filepath.Join(dir, "conf.d", "db.conf"), []byte("[db]\nhost=localhost\n"), 0644)
+	select {
+	case changes := <-reloaded:
+		if len(changes) != 1 || changes[0].String() != "+ db:host = localhost" {
+			t.Errorf("changes = %v", changes)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("new included file not reloaded")
+	}
+}
+
+// writeLater write the file with a new modification time
+func writeLater(file, content string) {
+	os.WriteFile(file, []byte(content), 0644)
+	later := time.Now().Add(time.Second)
+	if fi, err := os.Stat(file); err == nil && !fi.ModTime().Before(later) {
+		later = fi.ModTime().Add(time.Second)
+	}
+	os.Chtimes(file, later, later)
+}
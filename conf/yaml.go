@@ -0,0 +1,465 @@
+package conf
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// DecodeYAML decode the yaml subset used by config files and the i18n
+// catalogs: block mappings and sequences, flow sequences and mappings,
+// plain and quoted scalars, literal (|) and folded (>) block scalars.
+// Anchors, aliases, tags and multiple documents are not supported. The
+// scalars are kept as strings.
+func DecodeYAML(data []byte) (map[string]interface{}, error) {
+	p := &yamlParser{}
+	for i, raw := range strings.Split(strings.Replace(string(data), "\r\n", "\n", -1), "\n") {
+		p.lines = append(p.lines, newYAMLLine(raw, i+1))
+	}
+
+	if !p.skip() {
+		return map[string]interface{}{}, nil
+	}
+	if p.lines[p.pos].text == "---" {
+		p.pos++
+		if !p.skip() {
+			return map[string]interface{}{}, nil
+		}
+	}
+	first := p.lines[p.pos]
+	if first.indent != 0 || isSeqItem(first.text) {
+		return nil, fmt.Errorf("line %d: the root must be a mapping", first.num)
+	}
+	tree, err := p.parseMap(0)
+	if err != nil {
+		return nil, err
+	}
+	if p.skip() && p.lines[p.pos].text != "..." {
+		l := p.lines[p.pos]
+		return nil, fmt.Errorf("line %d: unexpected %q", l.num, l.text)
+	}
+	return tree, nil
+}
+
+type yamlLine struct {
+	num    int
+	raw    string
+	indent int
+	// text without indent, "" for blank and comment lines
+	text string
+	tab  bool
+}
+
+func newYAMLLine(raw string, num int) yamlLine {
+	text := strings.TrimLeft(raw, " ")
+	l := yamlLine{num: num, raw: raw, indent: len(raw) - len(text)}
+	l.tab = strings.HasPrefix(text, "\t")
+	text = strings.TrimRight(text, " \t\r")
+	if !strings.HasPrefix(text, "#") {
+		l.text = text
+	}
+	return l
+}
+
+type yamlParser struct {
+	lines []yamlLine
+	pos   int
+}
+
+// skip skip the blank and comment lines, return false at the end
+func (p *yamlParser) skip() bool {
+	for p.pos < len(p.lines) && strings.TrimSpace(p.lines[p.pos].text) == "" {
+		p.pos++
+	}
+	return p.pos < len(p.lines)
+}
+
+func isSeqItem(text string) bool {
+	return text == "-" || strings.HasPrefix(text, "- ")
+}
+
+// parseBlock parse the block of the current line
+func (p *yamlParser) parseBlock(indent int) (interface{}, error) {
+	if isSeqItem(p.lines[p.pos].text) {
+		return p.parseSeq(indent)
+	}
+	return p.parseMap(indent)
+}
+
+func (p *yamlParser) parseMap(indent int) (map[string]interface{}, error) {
+	m := map[string]interface{}{}
+	for p.skip() {
+		l := p.lines[p.pos]
+		if l.indent < indent || l.text == "..." || l.text == "---" {
+			break
+		}
+		if l.tab {
+			return nil, fmt.Errorf("line %d: tabs are not allowed for indentation", l.num)
+		}
+		if l.indent > indent {
+			return nil, fmt.Errorf("line %d: bad indentation", l.num)
+		}
+		if isSeqItem(l.text) {
+			return nil, fmt.Errorf("line %d: unexpected sequence item in mapping", l.num)
+		}
+		key, rest, ok := splitYAMLKey(l.text)
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected 'key: value'", l.num)
+		}
+		if _, dup := m[key]; dup {
+			return nil, fmt.Errorf("line %d: duplicate key %v", l.num, key)
+		}
+		p.pos++
+		val, err := p.parseValue(indent, rest, l.num, true)
+		if err != nil {
+			return nil, err
+		}
+		m[key] = val
+	}
+	return m, nil
+}
+
+func (p *yamlParser) parseSeq(indent int) ([]interface{}, error) {
+	seq := []interface{}{}
+	for p.skip() {
+		l := p.lines[p.pos]
+		if l.indent != indent || !isSeqItem(l.text) {
+			if l.indent > indent {
+				return nil, fmt.Errorf("line %d: bad indentation", l.num)
+			}
+			break
+		}
+		rest := strings.TrimLeft(l.text[1:], " ")
+		if _, _, isMap := splitYAMLKey(rest); isMap && rest[0] != '[' && rest[0] != '{' {
+			// "- key: value" start a mapping at the column of key
+			col := l.indent + len(l.text) - len(rest)
+			p.lines[p.pos] = yamlLine{num: l.num, raw: l.raw, indent: col, text: rest}
+			m, err := p.parseMap(col)
+			if err != nil {
+				return nil, err
+			}
+			seq = append(seq, m)
+			continue
+		}
+		p.pos++
+		val, err := p.parseValue(indent, rest, l.num, false)
+		if err != nil {
+			return nil, err
+		}
+		seq = append(seq, val)
+	}
+	return seq, nil
+}
+
+// parseValue parse the value after "key:" or "-", the nested block of a
+// mapping value may be a sequence at the same indent
+func (p *yamlParser) parseValue(indent int, rest string, num int, inMap bool) (interface{}, error) {
+	rest = stripYAMLComment(rest)
+	switch {
+	case rest == "":
+		if !p.skip() {
+			return nil, nil
+		}
+		next := p.lines[p.pos]
+		if next.indent > indent || (inMap && next.indent == indent && isSeqItem(next.text)) {
+			return p.parseBlock(next.indent)
+		}
+		return nil, nil
+	case rest[0] == '|' || rest[0] == '>':
+		return p.parseBlockScalar(indent, rest, num)
+	case rest[0] == '[' || rest[0] == '{':
+		fp := &flowParser{s: rest, num: num}
+		v, err := fp.parse()
+		if err != nil {
+			return nil, err
+		}
+		if fp.skipSpace(); fp.pos < len(fp.s) {
+			return nil, fmt.Errorf("line %d: unexpected %q after flow collection", num, fp.s[fp.pos:])
+		}
+		return v, nil
+	}
+	return yamlScalar(rest, num)
+}
+
+// parseBlockScalar parse the literal or folded block scalar
+func (p *yamlParser) parseBlockScalar(indent int, header string, num int) (interface{}, error) {
+	folded := header[0] == '>'
+	chomp := byte(0)
+	for _, ch := range header[1:] {
+		switch ch {
+		case '-', '+':
+			chomp = byte(ch)
+		default:
+			return nil, fmt.Errorf("line %d: unsupported block scalar header %q", num, header)
+		}
+	}
+
+	var lines []string
+	blockIndent := -1
+	for p.pos < len(p.lines) {
+		l := p.lines[p.pos]
+		if strings.TrimSpace(l.raw) == "" {
+			lines = append(lines, "")
+			p.pos++
+			continue
+		}
+		if l.indent <= indent {
+			break
+		}
+		if blockIndent < 0 {
+			blockIndent = l.indent
+		}
+		if l.indent < blockIndent {
+			return nil, fmt.Errorf("line %d: bad indentation of block scalar", l.num)
+		}
+		lines = append(lines, strings.TrimRight(l.raw[blockIndent:], "\r"))
+		p.pos++
+	}
+
+	// the trailing blank lines are kept by "+" only
+	trailing := 0
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+		trailing++
+	}
+	var text string
+	if folded {
+		var sb strings.Builder
+		for i, line := range lines {
+			if i > 0 {
+				// a single line break is folded into a space, the empty
+				// and the more indented lines keep their line breaks
+				switch {
+				case line == "" || strings.HasPrefix(line, " ") || strings.HasPrefix(lines[i-1], " "):
+					sb.WriteString("\n")
+				case lines[i-1] != "":
+					sb.WriteString(" ")
+				}
+			}
+			sb.WriteString(line)
+		}
+		text = sb.String()
+	} else {
+		text = strings.Join(lines, "\n")
+	}
+	switch {
+	case len(lines) == 0:
+	case chomp == '-':
+	case chomp == '+':
+		text += strings.Repeat("\n", trailing+1)
+	default:
+		text += "\n"
+	}
+	return text, nil
+}
+
+// splitYAMLKey split "key: rest", the key may be quoted
+func splitYAMLKey(text string) (string, string, bool) {
+	if text == "" {
+		return "", "", false
+	}
+	if text[0] == '"' || text[0] == '\'' {
+		end := yamlQuoteEnd(text)
+		if end < 0 || end+1 >= len(text) || text[end+1] != ':' {
+			return "", "", false
+		}
+		if end+2 < len(text) && text[end+2] != ' ' {
+			return "", "", false
+		}
+		key, err := yamlScalar(text[:end+1], 0)
+		if err != nil {
+			return "", "", false
+		}
+		return key.(string), text[end+2:], true
+	}
+	if text[0] == '[' || text[0] == '{' || text[0] == '#' {
+		return "", "", false
+	}
+	for i := 0; i < len(text); i++ {
+		if text[i] == '#' && i > 0 && text[i-1] == ' ' {
+			return "", "", false
+		}
+		if text[i] == ':' && (i == len(text)-1 || text[i+1] == ' ') {
+			return strings.TrimSpace(text[:i]), text[i+1:], i > 0
+		}
+	}
+	return "", "", false
+}
+
+// stripYAMLComment remove the comment of a value outside quotes
+func stripYAMLComment(s string) string {
+	s = strings.TrimSpace(s)
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		ch := s[i]
+		switch {
+		case quote == '"' && ch == '\\':
+			i++
+		case quote != 0:
+			if ch == quote {
+				quote = 0
+			}
+		case ch == '"' || ch == '\'':
+			if i == 0 || s[i-1] == ' ' || s[i-1] == '[' || s[i-1] == '{' || s[i-1] == ',' || s[i-1] == ':' {
+				quote = ch
+			}
+		case ch == '#' && (i == 0 || s[i-1] == ' '):
+			return strings.TrimSpace(s[:i])
+		}
+	}
+	return s
+}
+
+// yamlQuoteEnd return the index of the closing quote
+func yamlQuoteEnd(s string) int {
+	q := s[0]
+	for i := 1; i < len(s); i++ {
+		switch {
+		case q == '"' && s[i] == '\\':
+			i++
+		case s[i] == q:
+			if q == '\'' && i+1 < len(s) && s[i+1] == '\'' {
+				i++
+				continue
+			}
+			return i
+		}
+	}
+	return -1
+}
+
+// yamlScalar return the string of a scalar, nil for null
+func yamlScalar(s string, num int) (interface{}, error) {
+	switch s[0] {
+	case '"':
+		if yamlQuoteEnd(s) != len(s)-1 {
+			return nil, fmt.Errorf("line %d: invalid quoted string %s", num, s)
+		}
+		v, err := strconv.Unquote(s)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid quoted string %s", num, s)
+		}
+		return v, nil
+	case '\'':
+		if yamlQuoteEnd(s) != len(s)-1 {
+			return nil, fmt.Errorf("line %d: invalid quoted string %s", num, s)
+		}
+		return strings.Replace(s[1:len(s)-1], "''", "'", -1), nil
+	case '&', '*', '!':
+		return nil, fmt.Errorf("line %d: anchors, aliases and tags are not supported", num)
+	}
+	switch s {
+	case "~", "null", "Null", "NULL":
+		return nil, nil
+	}
+	return s, nil
+}
+
+// flowParser parse the flow collections [a, b] and {a: 1}
+type flowParser struct {
+	s   string
+	pos int
+	num int
+}
+
+func (p *flowParser) skipSpace() {
+	for p.pos < len(p.s) && p.s[p.pos] == ' ' {
+		p.pos++
+	}
+}
+
+func (p *flowParser) parse() (interface{}, error) {
+	p.skipSpace()
+	if p.pos >= len(p.s) {
+		return nil, fmt.Errorf("line %d: unexpected end of flow collection", p.num)
+	}
+	switch p.s[p.pos] {
+	case '[':
+		p.pos++
+		seq := []interface{}{}
+		for {
+			p.skipSpace()
+			if p.pos < len(p.s) && p.s[p.pos] == ']' {
+				p.pos++
+				return seq, nil
+			}
+			v, err := p.parse()
+			if err != nil {
+				return nil, err
+			}
+			seq = append(seq, v)
+			if err = p.separator(']'); err != nil {
+				return nil, err
+			}
+		}
+	case '{':
+		p.pos++
+		m := map[string]interface{}{}
+		for {
+			p.skipSpace()
+			if p.pos < len(p.s) && p.s[p.pos] == '}' {
+				p.pos++
+				return m, nil
+			}
+			k, err := p.parse()
+			if err != nil {
+				return nil, err
+			}
+			key, ok := k.(string)
+			if !ok {
+				return nil, fmt.Errorf("line %d: invalid key in flow mapping", p.num)
+			}
+			p.skipSpace()
+			if p.pos >= len(p.s) || p.s[p.pos] != ':' {
+				return nil, fmt.Errorf("line %d: expected ':' in flow mapping", p.num)
+			}
+			p.pos++
+			v, err := p.parse()
+			if err != nil {
+				return nil, err
+			}
+			if _, dup := m[key]; dup {
+				return nil, fmt.Errorf("line %d: duplicate key %v", p.num, key)
+			}
+			m[key] = v
+			if err = p.separator('}'); err != nil {
+				return nil, err
+			}
+		}
+	case '"', '\'':
+		end := yamlQuoteEnd(p.s[p.pos:])
+		if end < 0 {
+			return nil, fmt.Errorf("line %d: unterminated quoted string", p.num)
+		}
+		v, err := yamlScalar(p.s[p.pos:p.pos+end+1], p.num)
+		p.pos += end + 1
+		return v, err
+	}
+
+	start := p.pos
+	for p.pos < len(p.s) && !strings.ContainsRune(",[]{}", rune(p.s[p.pos])) &&
+		!(p.s[p.pos] == ':' && (p.pos+1 == len(p.s) || p.s[p.pos+1] == ' ')) {
+		p.pos++
+	}
+	text := strings.TrimSpace(p.s[start:p.pos])
+	if text == "" {
+		return nil, fmt.Errorf("line %d: empty value in flow collection", p.num)
+	}
+	return yamlScalar(text, p.num)
+}
+
+// separator consume "," or the end of the collection
+func (p *flowParser) separator(end byte) error {
+	p.skipSpace()
+	if p.pos >= len(p.s) {
+		return fmt.Errorf("line %d: unterminated flow collection", p.num)
+	}
+	switch p.s[p.pos] {
+	case ',':
+		p.pos++
+		return nil
+	case end:
+		return nil
+	}
+	return fmt.Errorf("line %d: expected ',' or '%c' in flow collection", p.num, end)
+}
@@ -3,6 +3,8 @@ package error
 import (
 	"fmt"
 	"strings"
+
+	"github.com/QunQunLab/ego/i18n"
 )
 
 const (
@@ -12,23 +14,100 @@ const (
 // Errorf error format output
 // Multi language support
 // var ErrorPass = &Errorf{Code: 1, Msg: map[string]string{"cn": "密码错误", "en": "Invalid password"}}
+//
+// Key refer to a message of the i18n catalogs, Msg is used if the key is
+// not found, the named placeholders of the message are replaced by Data
+// var ErrorPass = &Errorf{Code: 1, Key: "user.invalid_password", Msg: "Invalid password"}
+//
+// An Errorf is a template shared by goroutines, it must not be modified
+// after defined. New, WithData and Wrap return fresh instances:
+// panic(ErrorPass.New(uid, map[string]interface{}{"retry": 3}))
 type Errorf struct {
 	Code int
+	Key  string
 	// string or map[string]string
-	Msg  interface{}
+	Msg interface{}
+	// the arguments of Msg, a trailing map[string]interface{} is the Data
 	Fmt  []interface{}
 	Data map[string]interface{}
+	// the wrapped error, see Wrap
+	Cause error
+
+	stack []uintptr
+}
+
+// New return a copy of e with the arguments of the message, a trailing
+// map[string]interface{} of args is merged into the Data. The stack of
+// the caller is captured.
+func (e *Errorf) New(args ...interface{}) *Errorf {
+	return wrap(e.Cause, e, 3, args...)
+}
+
+// WithData return a copy of e with data merged into the Data
+func (e *Errorf) WithData(data map[string]interface{}) *Errorf {
+	c := *e
+	c.Data = mergeData(e.Data, data)
+	return &c
 }
 
 func (e *Errorf) GetCode() int {
 	return e.Code
 }
 
+// args return the arguments of the message and the data, the trailing
+// map of Fmt is the data
+func (e *Errorf) args() ([]interface{}, map[string]interface{}) {
+	if n := len(e.Fmt); n > 0 {
+		if data, ok := e.Fmt[n-1].(map[string]interface{}); ok {
+			if e.Data == nil {
+				return e.Fmt[:n-1], data
+			}
+			return e.Fmt[:n-1], mergeData(e.Data, data)
+		}
+	}
+	return e.Fmt, e.Data
+}
+
+// mergeData return a new map of a and b, b wins
+func mergeData(a, b map[string]interface{}) map[string]interface{} {
+	if a == nil && b == nil {
+		return nil
+	}
+	data := make(map[string]interface{}, len(a)+len(b))
+	for k, v := range a {
+		data[k] = v
+	}
+	for k, v := range b {
+		data[k] = v
+	}
+	return data
+}
+
 func (e *Errorf) GetMsg(langs ...string) string {
-	if len(e.Fmt) > 0 {
-		if data, ok := e.Fmt[len(e.Fmt)-1].(map[string]interface{}); ok {
-			e.Fmt = e.Fmt[0 : len(e.Fmt)-1]
-			e.Data = data
+	return e.GetBundleMsg(nil, langs...)
+}
+
+// GetBundleMsg is like GetMsg, the Key is looked up in b, or the default
+// bundle if b is nil
+func (e *Errorf) GetBundleMsg(b *i18n.Bundle, langs ...string) string {
+	args, data := e.args()
+
+	if e.Key != "" {
+		var lang string
+		if len(langs) > 0 {
+			lang = langs[0]
+		}
+		if b == nil {
+			b = i18n.Default()
+		}
+		if msg, ok := b.Lookup(lang, e.Key, data); ok {
+			if len(args) > 0 {
+				return fmt.Sprintf(msg, args...)
+			}
+			return msg
+		}
+		if e.Msg == nil {
+			return e.Key
 		}
 	}
 
@@ -40,7 +119,7 @@ func (e *Errorf) GetMsg(langs ...string) string {
 		lang string
 	)
 	if msg, ok = e.Msg.(string); ok {
-		return fmt.Sprintf(msg, e.Fmt...)
+		return fmt.Sprintf(msg, args...)
 	} else if gMsg, ok = e.Msg.(map[string]string); ok {
 		if len(langs) > 0 {
 			lang = strings.ToLower(langs[0])
@@ -48,16 +127,16 @@ func (e *Errorf) GetMsg(langs ...string) string {
 
 		if lang != "" {
 			if msg, ok = gMsg[lang]; ok {
-				return fmt.Sprintf(msg, e.Fmt...)
+				return fmt.Sprintf(msg, args...)
 			}
 		}
 
 		if msg, ok = gMsg[defaultLanguage]; ok {
-			return fmt.Sprintf(msg, e.Fmt...)
+			return fmt.Sprintf(msg, args...)
 		}
 
 		for _, v := range gMsg {
-			return fmt.Sprintf(v, e.Fmt...)
+			return fmt.Sprintf(v, args...)
 		}
 	}
 
@@ -65,15 +144,13 @@ func (e *Errorf) GetMsg(langs ...string) string {
 }
 
 func (e *Errorf) GetData() map[string]interface{} {
-	if e.Data == nil && len(e.Fmt) > 0 {
-		if data, ok := e.Fmt[len(e.Fmt)-1].(map[string]interface{}); ok {
-			return data
-		}
-	}
-
-	return e.Data
+	_, data := e.args()
+	return data
 }
 
 func (e *Errorf) Error() string {
+	if e.Cause != nil {
+		return e.GetMsg() + ": " + e.Cause.Error()
+	}
 	return e.GetMsg()
 }
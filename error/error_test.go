@@ -0,0 +1,123 @@
+package error
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/QunQunLab/ego/i18n"
+)
+
+func TestErrorfKey(t *testing.T) {
+	b := i18n.NewBundle("cn")
+	b.AddStrings("en", map[string]string{"user.frozen": "user {name} is frozen"})
+	i18n.SetDefault(b)
+	defer i18n.SetDefault(nil)
+
+	e := &Errorf{Code: 1, Key: "user.frozen", Msg: map[string]string{"cn": "用户已冻结"}, Data: map[string]interface{}{"name": "ego"}}
+	if msg := e.GetMsg("en-US"); msg != "user ego is frozen" {
+		t.Errorf("en msg = %v", msg)
+	}
+	// the key is not translated in cn, fallback to Msg
+	if msg := e.GetMsg("cn"); msg != "用户已冻结" {
+		t.Errorf("cn msg = %v", msg)
+	}
+	if msg := (&Errorf{Key: "user.missing"}).GetMsg("en"); msg != "user.missing" {
+		t.Errorf("missing msg = %v", msg)
+	}
+}
+
+var errQuery = &Errorf{Code: 2, Msg: "query %v failed"}
+
+func query() *Errorf {
+	return Wrap(errors.New("connection refused"), errQuery, "user")
+}
+
+func TestWrap(t *testing.T) {
+	e := query()
+	if e.Error() != "query user failed: connection refused" {
+		t.Errorf("Error() = %v", e.Error())
+	}
+	if !errors.Is(e, errQuery) || !errors.Is(e, &Errorf{Code: 2}) || errors.Is(e, &Errorf{Code: 3}) {
+		t.Error("errors.Is by code")
+	}
+	if errors.Is(e, (*Errorf)(nil)) {
+		t.Error("errors.Is nil *Errorf")
+	}
+	if errQuery.Cause != nil || len(errQuery.Fmt) != 0 {
+		t.Error("Wrap changed the original")
+	}
+
+	outer := Wrap(e, &Errorf{Code: 3, Msg: "load profile failed"})
+	var target *Errorf
+	if !errors.As(outer.Unwrap(), &target) || target.Code != 2 {
+		t.Errorf("errors.As got %v", target)
+	}
+	if !errors.Is(outer, errQuery) {
+		t.Error("errors.Is through the chain")
+	}
+
+	detail := fmt.Sprintf("%+v", outer)
+	for _, want := range []string{"[3] load profile failed", "error.TestWrap", "caused by: [2] query user failed", "error.query", "caused by: connection refused"} {
+		if !strings.Contains(detail, want) {
+			t.Errorf("%%+v missing %q:\n%v", want, detail)
+		}
+	}
+	if s := fmt.Sprintf("%v", outer); s != "load profile failed: query user failed: connection refused" {
+		t.Errorf("%%v = %v", s)
+	}
+}
+
+func TestNewWithData(t *testing.T) {
+	tpl := &Errorf{Code: 4, Msg: map[string]string{"cn": "余额不足:%v", "en": "balance %v is not enough"}, Data: map[string]interface{}{"a": 1}}
+
+	e := tpl.New(10, map[string]interface{}{"b": 2})
+	if msg := e.GetMsg("en"); msg != "balance 10 is not enough" {
+		t.Errorf("msg = %v", msg)
+	}
+	if d := e.GetData(); len(d) != 2 || d["a"] != 1 || d["b"] != 2 {
+		t.Errorf("data = %v", d)
+	}
+	if len(e.StackTrace()) == 0 {
+		t.Error("New without stack")
+	}
+
+	d := e.WithData(map[string]interface{}{"a": 3})
+	if d.GetData()["a"] != 3 || e.GetData()["a"] != 1 {
+		t.Errorf("WithData = %v, original = %v", d.GetData(), e.GetData())
+	}
+	if len(tpl.Fmt) != 0 || len(tpl.Data) != 1 {
+		t.Errorf("template changed: %+v", tpl)
+	}
+}
+
+// run with -race
+func TestConcurrentRender(t *testing.T) {
+	tpl := &Errorf{Code: 5, Msg: "%v failed", Fmt: []interface{}{"login", map[string]interface{}{"retry": 3}}}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				if msg := tpl.GetMsg("en"); msg != "login failed" {
+					t.Errorf("msg = %v", msg)
+					return
+				}
+				if tpl.GetData()["retry"] != 3 {
+					t.Errorf("data = %v", tpl.GetData())
+					return
+				}
+				e := tpl.New(i).WithData(map[string]interface{}{"i": i})
+				if e.Error() != fmt.Sprintf("%v failed", i) || e.GetData()["i"] != i {
+					t.Errorf("instance = %v %v", e, e.GetData())
+					return
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+}
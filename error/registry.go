@@ -0,0 +1,175 @@
+package error
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Entry a registered error
+type Entry struct {
+	Code     int               `json:"code"`
+	Status   int               `json:"status"`
+	Category string            `json:"category"`
+	Key      string            `json:"key,omitempty"`
+	Msg      map[string]string `json:"msg"`
+}
+
+// Registry the errors with unique codes and their http status
+type Registry struct {
+	mu      sync.RWMutex
+	entries map[int]*Entry
+}
+
+// NewRegistry return an empty registry
+func NewRegistry() *Registry {
+	return &Registry{entries: map[int]*Entry{}}
+}
+
+// Register register e with the http status and category, it returns e so
+// that errors can be defined and registered at once. It panics if the code
+// is registered, so duplicate codes are found at startup.
+//
+//	var ErrUserNotFound = error.Register(error.Errorf{Code: 20001, Msg: "user not found"}, http.StatusNotFound, "user")
+func (r *Registry) Register(e Errorf, status int, category string) Errorf {
+	if err := r.Add(&e, status, category); err != nil {
+		panic(err)
+	}
+	return e
+}
+
+// Add register e, an error is returned if the code is registered
+func (r *Registry) Add(e *Errorf, status int, category string) error {
+	if status < 100 || status > 599 {
+		return fmt.Errorf("error code %v: invalid http status %v", e.Code, status)
+	}
+	entry := &Entry{
+		Code:     e.Code,
+		Status:   status,
+		Category: category,
+		Key:      e.Key,
+		Msg:      map[string]string{},
+	}
+	switch msg := e.Msg.(type) {
+	case string:
+		entry.Msg[defaultLanguage] = msg
+	case map[string]string:
+		for k, v := range msg {
+			entry.Msg[k] = v
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if exist, ok := r.entries[e.Code]; ok {
+		return fmt.Errorf("error code %v of %v is registered by %v", e.Code, category, exist.Category)
+	}
+	r.entries[e.Code] = entry
+	return nil
+}
+
+// Lookup return the entry of code
+func (r *Registry) Lookup(code int) (Entry, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if entry, ok := r.entries[code]; ok {
+		return *entry, true
+	}
+	return Entry{}, false
+}
+
+// Status return the http status of code, or def if not registered
+func (r *Registry) Status(code int, def int) int {
+	if entry, ok := r.Lookup(code); ok {
+		return entry.Status
+	}
+	return def
+}
+
+// Entries return the entries sorted by code
+func (r *Registry) Entries() []Entry {
+	r.mu.RLock()
+	entries := make([]Entry, 0, len(r.entries))
+	for _, entry := range r.entries {
+		entries = append(entries, *entry)
+	}
+	r.mu.RUnlock()
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Code < entries[j].Code
+	})
+	return entries
+}
+
+// DumpJSON write the entries as a json array
+func (r *Registry) DumpJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r.Entries())
+}
+
+// DumpMarkdown write the entries as a markdown table
+func (r *Registry) DumpMarkdown(w io.Writer) error {
+	var sb strings.Builder
+	sb.WriteString("| Code | HTTP Status | Category | Key | Message |\n")
+	sb.WriteString("| ---: | ---: | --- | --- | --- |\n")
+	for _, entry := range r.Entries() {
+		langs := make([]string, 0, len(entry.Msg))
+		for lang := range entry.Msg {
+			langs = append(langs, lang)
+		}
+		sort.Strings(langs)
+		msgs := make([]string, len(langs))
+		for i, lang := range langs {
+			msgs[i] = lang + ": " + markdownEscape(entry.Msg[lang])
+		}
+		fmt.Fprintf(&sb, "| %d | %d %s | %s | %s | %s |\n",
+			entry.Code, entry.Status, http.StatusText(entry.Status),
+			markdownEscape(entry.Category), markdownEscape(entry.Key), strings.Join(msgs, "<br>"))
+	}
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+func markdownEscape(s string) string {
+	return strings.NewReplacer("|", `\|`, "\n", " ").Replace(s)
+}
+
+var registry = NewRegistry()
+
+// Register register e to the default registry, see Registry.Register
+func Register(e Errorf, status int, category string) Errorf {
+	if err := registry.Add(&e, status, category); err != nil {
+		panic(err)
+	}
+	return e
+}
+
+// Lookup return the entry of code in the default registry
+func Lookup(code int) (Entry, bool) {
+	return registry.Lookup(code)
+}
+
+// Status return the http status of code in the default registry, or def
+// if not registered
+func Status(code int, def int) int {
+	return registry.Status(code, def)
+}
+
+// Entries return the entries of the default registry sorted by code
+func Entries() []Entry {
+	return registry.Entries()
+}
+
+// DumpJSON write the default registry as json
+func DumpJSON(w io.Writer) error {
+	return registry.DumpJSON(w)
+}
+
+// DumpMarkdown write the default registry as a markdown table
+func DumpMarkdown(w io.Writer) error {
+	return registry.DumpMarkdown(w)
+}
@@ -0,0 +1,61 @@
+package error
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestRegistry(t *testing.T) {
+	r := NewRegistry()
+	notFound := r.Register(Errorf{Code: 20001, Key: "user.not_found", Msg: map[string]string{"cn": "用户不存在", "en": "user | not found"}}, http.StatusNotFound, "user")
+	r.Register(Errorf{Code: 10, Msg: "ok"}, http.StatusOK, "general")
+	if notFound.Code != 20001 {
+		t.Errorf("Register returned %+v", notFound)
+	}
+
+	if err := r.Add(&Errorf{Code: 20001}, http.StatusBadRequest, "order"); err == nil {
+		t.Error("duplicate code registered")
+	}
+	if err := r.Add(&Errorf{Code: 20002}, 999, "order"); err == nil {
+		t.Error("invalid status registered")
+	}
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("Register duplicate code not panic")
+			}
+		}()
+		r.Register(Errorf{Code: 10}, http.StatusOK, "general")
+	}()
+
+	if s := r.Status(20001, http.StatusOK); s != http.StatusNotFound {
+		t.Errorf("status = %v", s)
+	}
+	if s := r.Status(1, http.StatusOK); s != http.StatusOK {
+		t.Errorf("unregistered status = %v", s)
+	}
+
+	var buf bytes.Buffer
+	if err := r.DumpJSON(&buf); err != nil {
+		t.Fatal(err)
+	}
+	var entries []Entry
+	if err := json.Unmarshal(buf.Bytes(), &entries); err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 || entries[0].Code != 10 || entries[1].Msg["en"] != "user | not found" {
+		t.Errorf("json dump = %+v", entries)
+	}
+
+	buf.Reset()
+	if err := r.DumpMarkdown(&buf); err != nil {
+		t.Fatal(err)
+	}
+	want := `| 20001 | 404 Not Found | user | user.not_found | cn: 用户不存在<br>en: user \| not found |`
+	if !strings.Contains(buf.String(), want) {
+		t.Errorf("markdown dump:\n%v", buf.String())
+	}
+}
@@ -0,0 +1,110 @@
+package error
+
+import (
+	"fmt"
+	"io"
+	"runtime"
+	"strconv"
+)
+
+const maxStackDepth = 32
+
+// Wrap return a copy of e caused by cause, args are the Fmt of the
+// message, the stack of the caller is captured
+//
+//	if err := db.Find(&user); err != nil {
+//		panic(error.Wrap(err, ErrUserQuery, uid))
+//	}
+func Wrap(cause error, e *Errorf, args ...interface{}) *Errorf {
+	return wrap(cause, e, 3, args...)
+}
+
+// WithStack return a copy of e with the stack of the caller, skip is the
+// number of the callers to skip, such as the helpers panic with errors
+func WithStack(e *Errorf, skip int, args ...interface{}) *Errorf {
+	return wrap(e.Cause, e, skip+3, args...)
+}
+
+func wrap(cause error, e *Errorf, skip int, args ...interface{}) *Errorf {
+	w := &Errorf{
+		Code:  e.Code,
+		Key:   e.Key,
+		Msg:   e.Msg,
+		Fmt:   e.Fmt,
+		Data:  e.Data,
+		Cause: cause,
+	}
+	if n := len(args); n > 0 {
+		w.Fmt = args
+		if data, ok := args[n-1].(map[string]interface{}); ok {
+			w.Fmt = args[:n-1]
+			w.Data = mergeData(e.Data, data)
+		}
+	}
+	pcs := make([]uintptr, maxStackDepth)
+	w.stack = pcs[:runtime.Callers(skip, pcs)]
+	return w
+}
+
+// Unwrap return the cause, it's used by errors.Is and errors.As
+func (e *Errorf) Unwrap() error {
+	return e.Cause
+}
+
+// Is report whether target is an Errorf of the same Code, so that
+// errors.Is(err, ErrUserQuery) matches the wrapped copies
+func (e *Errorf) Is(target error) bool {
+	t, ok := target.(*Errorf)
+	if !ok || t == nil || e == nil {
+		return ok && t == e
+	}
+	return t.Code == e.Code
+}
+
+// StackTrace return the frames captured by Wrap
+func (e *Errorf) StackTrace() []runtime.Frame {
+	if len(e.stack) == 0 {
+		return nil
+	}
+	var result []runtime.Frame
+	frames := runtime.CallersFrames(e.stack)
+	for {
+		frame, more := frames.Next()
+		result = append(result, frame)
+		if !more {
+			break
+		}
+	}
+	return result
+}
+
+// Format implement fmt.Formatter, %+v print the code, the message, the
+// stack and the causes of the chain
+//
+//	[10001] query user failed
+//	main.(*UserController).Info
+//		/app/controller/user.go:30
+//	...
+//	caused by: sql: no rows in result set
+func (e *Errorf) Format(s fmt.State, verb rune) {
+	switch verb {
+	case 'v':
+		if s.Flag('+') {
+			io.WriteString(s, "["+strconv.Itoa(e.Code)+"] "+e.GetMsg())
+			for _, frame := range e.StackTrace() {
+				fmt.Fprintf(s, "\n%s\n\t%s:%d", frame.Function, frame.File, frame.Line)
+			}
+			if e.Cause != nil {
+				fmt.Fprintf(s, "\ncaused by: %+v", e.Cause)
+			}
+			return
+		}
+		io.WriteString(s, e.Error())
+	case 's':
+		io.WriteString(s, e.Error())
+	case 'q':
+		fmt.Fprintf(s, "%q", e.Error())
+	default:
+		fmt.Fprintf(s, "%%!%c(*error.Errorf=%s)", verb, e.Error())
+	}
+}
@@ -0,0 +1,345 @@
+// Package i18n loads message catalogs of locales from json, yaml and po
+// files, and translates message keys with plural forms and named
+// placeholders.
+//
+//	locales/en.json   {"user.not_found": "user {name} not found",
+//	                   "cart.items": {"one": "{count} item", "other": "{count} items"}}
+//	locales/cn.yaml   user.not_found: 用户{name}不存在
+//
+//	[i18n]
+//	dir = ./locales
+//	default = cn
+//
+//	i18n.T("en-US", "user.not_found", i18n.Args{"name": "ego"})
+//	i18n.N("en", "cart.items", 3)
+package i18n
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/QunQunLab/ego/conf"
+	"github.com/QunQunLab/ego/log"
+)
+
+// Args the named placeholders of a message, an integer "count" selects
+// the plural form
+type Args map[string]interface{}
+
+// Message a translation, keyed by the plural categories zero, one, two,
+// few, many and other. A message without plural forms has "other" only.
+type Message map[string]string
+
+// Bundle the catalogs of locales, it's safe for concurrent use
+type Bundle struct {
+	mu          sync.RWMutex
+	defaultLang string
+	catalogs    map[string]map[string]Message
+}
+
+// NewBundle return an empty bundle, defaultLang is the last of all the
+// fallback chains
+func NewBundle(defaultLang string) *Bundle {
+	return &Bundle{
+		defaultLang: normalize(defaultLang),
+		catalogs:    map[string]map[string]Message{},
+	}
+}
+
+// DefaultLang return the default language
+func (b *Bundle) DefaultLang() string {
+	return b.defaultLang
+}
+
+// Langs return the loaded languages
+func (b *Bundle) Langs() []string {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	langs := make([]string, 0, len(b.catalogs))
+	for l := range b.catalogs {
+		langs = append(langs, l)
+	}
+	sort.Strings(langs)
+	return langs
+}
+
+// Add add messages to the catalog of lang, the existing keys are replaced
+func (b *Bundle) Add(lang string, messages map[string]Message) {
+	lang = normalize(lang)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	catalog := b.catalogs[lang]
+	if catalog == nil {
+		catalog = map[string]Message{}
+		b.catalogs[lang] = catalog
+	}
+	for k, m := range messages {
+		catalog[k] = m
+	}
+}
+
+// AddStrings add messages without plural forms to the catalog of lang
+func (b *Bundle) AddStrings(lang string, messages map[string]string) {
+	m := make(map[string]Message, len(messages))
+	for k, v := range messages {
+		m[k] = Message{"other": v}
+	}
+	b.Add(lang, m)
+}
+
+// Load load a catalog file by the extension, .json, .yaml, .yml or .po.
+// The language is the last dot separated part of the file name, such as
+// en.json, zh-TW.po or messages.fr.yaml.
+func (b *Bundle) Load(file string) error {
+	return b.LoadFS(os.DirFS(filepath.Dir(file)), filepath.Base(file))
+}
+
+// LoadFS load a catalog file of fsys, see Load
+func (b *Bundle) LoadFS(fsys fs.FS, file string) error {
+	ext := strings.ToLower(path.Ext(file))
+	name := strings.TrimSuffix(path.Base(file), path.Ext(file))
+	lang := name[strings.LastIndex(name, ".")+1:]
+
+	data, err := fs.ReadFile(fsys, file)
+	if err != nil {
+		return err
+	}
+	var messages map[string]Message
+	switch ext {
+	case ".json":
+		messages, err = parseJSON(data)
+	case ".yaml", ".yml":
+		messages, err = parseYAML(data)
+	case ".po":
+		messages, err = parsePO(data, lang)
+	default:
+		return fmt.Errorf("i18n: unknown catalog format %v", file)
+	}
+	if err != nil {
+		return fmt.Errorf("i18n: %v: %v", file, err)
+	}
+	b.Add(lang, messages)
+	return nil
+}
+
+// LoadDir load all the catalog files in the directory
+func (b *Bundle) LoadDir(dir string) error {
+	return b.LoadDirFS(os.DirFS(dir), ".")
+}
+
+// LoadDirFS load all the catalog files in the directory of fsys,
+// such as an embed.FS
+func (b *Bundle) LoadDirFS(fsys fs.FS, dir string) error {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		switch strings.ToLower(path.Ext(entry.Name())) {
+		case ".json", ".yaml", ".yml", ".po":
+			if err = b.LoadFS(fsys, path.Join(dir, entry.Name())); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Lookup return the message of key in the first language of the fallback
+// chain having it, such as zh-tw, zh and the default language, then the
+// placeholders are replaced by args
+func (b *Bundle) Lookup(lang, key string, args Args) (string, bool) {
+	b.mu.RLock()
+	var (
+		m     Message
+		found string
+	)
+	for _, l := range b.chain(lang) {
+		if msg, ok := b.catalogs[l][key]; ok {
+			m, found = msg, l
+			break
+		}
+	}
+	b.mu.RUnlock()
+	if m == nil {
+		return "", false
+	}
+
+	var (
+		text string
+		ok   bool
+	)
+	if n, isCount := count(args); isCount {
+		if n == 0 {
+			text, ok = m["zero"]
+		}
+		if !ok {
+			text, ok = m[pluralCategory(found, n)]
+		}
+	}
+	if !ok {
+		text, ok = m["other"]
+	}
+	if !ok {
+		for _, v := range m {
+			text = v
+			break
+		}
+	}
+	return replace(text, args), true
+}
+
+// T return the translation of key, or the key itself if not found
+func (b *Bundle) T(lang, key string, args ...Args) string {
+	var a Args
+	if len(args) > 0 {
+		a = args[0]
+	}
+	if text, ok := b.Lookup(lang, key, a); ok {
+		return text
+	}
+	return key
+}
+
+// N return the plural translation of key for n, {count} is n
+func (b *Bundle) N(lang, key string, n int, args ...Args) string {
+	a := Args{}
+	if len(args) > 0 {
+		for k, v := range args[0] {
+			a[k] = v
+		}
+	}
+	a["count"] = n
+	return b.T(lang, key, a)
+}
+
+// chain return the fallback languages of lang
+func (b *Bundle) chain(lang string) []string {
+	lang = normalize(lang)
+	var chain []string
+	for lang != "" {
+		chain = append(chain, lang)
+		i := strings.LastIndex(lang, "-")
+		if i < 0 {
+			break
+		}
+		lang = lang[:i]
+	}
+	if b.defaultLang != "" && (len(chain) == 0 || chain[len(chain)-1] != b.defaultLang) {
+		chain = append(chain, b.defaultLang)
+	}
+	return chain
+}
+
+// normalize zh_TW to zh-tw
+func normalize(lang string) string {
+	return strings.ToLower(strings.Replace(strings.TrimSpace(lang), "_", "-", -1))
+}
+
+// count return the integer value of args["count"]
+func count(args Args) (int64, bool) {
+	v := reflect.ValueOf(args["count"])
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int(), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return int64(v.Uint()), true
+	}
+	return 0, false
+}
+
+// replace replace the {name} placeholders by args, unknown placeholders
+// are kept
+func replace(text string, args Args) string {
+	if len(args) == 0 || !strings.Contains(text, "{") {
+		return text
+	}
+	var sb strings.Builder
+	for {
+		start := strings.IndexByte(text, '{')
+		if start < 0 {
+			break
+		}
+		end := strings.IndexByte(text[start:], '}')
+		if end < 0 {
+			break
+		}
+		end += start
+		sb.WriteString(text[:start])
+		if v, ok := args[text[start+1:end]]; ok {
+			sb.WriteString(fmt.Sprint(v))
+		} else {
+			sb.WriteString(text[start : end+1])
+		}
+		text = text[end+1:]
+	}
+	sb.WriteString(text)
+	return sb.String()
+}
+
+var (
+	defaultMu     sync.Mutex
+	defaultBundle *Bundle
+)
+
+// Default return the bundle loaded from the i18n section of the global
+// config, or the one set by SetDefault
+func Default() *Bundle {
+	defaultMu.Lock()
+	defer defaultMu.Unlock()
+	if defaultBundle == nil {
+		defaultBundle = FromConfig(conf.Global())
+	}
+	return defaultBundle
+}
+
+func init() {
+	conf.RegisterSection("i18n")
+}
+
+// FromConfig return a new bundle loaded from the i18n section of c
+//
+//	[i18n]
+//	dir = ./i18n
+//	default = cn
+func FromConfig(c *conf.Config) *Bundle {
+	dir, lang := "", "cn"
+	if section := c.Get("i18n"); section != nil {
+		dir, _ = section.String("dir")
+		lang, _ = section.String("default", lang)
+	}
+	b := NewBundle(lang)
+	if dir != "" {
+		if err := b.LoadDir(dir); err != nil {
+			log.Error("i18n load dir:%v err:%v", dir, err)
+		}
+	}
+	return b
+}
+
+// SetDefault set the default bundle
+func SetDefault(b *Bundle) {
+	defaultMu.Lock()
+	defer defaultMu.Unlock()
+	defaultBundle = b
+}
+
+// T translate key by the default bundle
+func T(lang, key string, args ...Args) string {
+	return Default().T(lang, key, args...)
+}
+
+// N translate key for n by the default bundle
+func N(lang, key string, n int, args ...Args) string {
+	return Default().N(lang, key, n, args...)
+}
@@ -0,0 +1,95 @@
+package i18n
+
+import (
+	"testing"
+	"testing/fstest"
+)
+
+var testCatalogs = fstest.MapFS{
+	"en.json": {Data: []byte(`{
+		"user": {"not_found": "user {name} not found"},
+		"cart.items": {"zero": "no items", "one": "{count} item", "other": "{count} items"}
+	}`)},
+	"zh.yaml": {Data: []byte(`# chinese
+user:
+  not_found: "用户{name}不存在"   # quoted
+  hello: '你好 ''{name}'''
+cart.items:
+  other: "{count}件商品"
+`)},
+	"zh-TW.po": {Data: []byte(`msgid ""
+msgstr ""
+"Language: zh_TW\n"
+
+msgctxt "user"
+msgid "not_found"
+msgstr "用戶{name}"
+"不存在"
+
+#, fuzzy
+msgid "user.hello"
+msgstr "fuzzy"
+`)},
+	"messages.ru.po": {Data: []byte(`msgid "cart.items"
+msgid_plural "cart.items"
+msgstr[0] "{count} товар"
+msgstr[1] "{count} товара"
+msgstr[2] "{count} товаров"
+`)},
+	"README.md": {Data: []byte(`ignored`)},
+}
+
+func TestBundle(t *testing.T) {
+	b := NewBundle("en")
+	if err := b.LoadDirFS(testCatalogs, "."); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		lang, key string
+		args      Args
+		want      string
+	}{
+		{"en", "user.not_found", Args{"name": "ego"}, "user ego not found"},
+		{"en-US", "user.not_found", Args{"name": "ego"}, "user ego not found"},
+		{"zh", "user.not_found", Args{"name": "ego"}, "用户ego不存在"},
+		{"zh_TW", "user.not_found", Args{"name": "ego"}, "用戶ego不存在"},
+		// fuzzy entry is skipped, fallback to zh
+		{"zh-TW", "user.hello", Args{"name": "ego"}, "你好 'ego'"},
+		// fallback to default
+		{"fr", "user.not_found", Args{"name": "ego"}, "user ego not found"},
+		{"", "user.not_found", nil, "user {name} not found"},
+		{"en", "cart.items", Args{"count": 0}, "no items"},
+		{"en", "cart.items", Args{"count": 1}, "1 item"},
+		{"en", "cart.items", Args{"count": uint8(5)}, "5 items"},
+		{"zh", "cart.items", Args{"count": 1}, "1件商品"},
+		{"ru", "cart.items", Args{"count": 21}, "21 товар"},
+		{"ru", "cart.items", Args{"count": 3}, "3 товара"},
+		{"ru", "cart.items", Args{"count": 11}, "11 товаров"},
+		{"en", "missing", nil, "missing"},
+	}
+	for _, c := range cases {
+		if got := b.T(c.lang, c.key, c.args); got != c.want {
+			t.Errorf("T(%v, %v, %v) = %v, want %v", c.lang, c.key, c.args, got, c.want)
+		}
+	}
+
+	if got := b.N("en", "cart.items", 2); got != "2 items" {
+		t.Errorf("N got %v", got)
+	}
+	if langs := b.Langs(); len(langs) != 4 {
+		t.Errorf("langs = %v", langs)
+	}
+}
+
+func TestParseError(t *testing.T) {
+	if _, err := parseYAML([]byte("a:\n  b c\n")); err == nil {
+		t.Error("yaml without ':' parsed")
+	}
+	if _, err := parsePO([]byte("msgid \"a\"\nmsgstr \"b\n"), "en"); err == nil {
+		t.Error("po with unterminated string parsed")
+	}
+	if _, err := parseJSON([]byte(`{"a": 1}`)); err == nil {
+		t.Error("json number message parsed")
+	}
+}
@@ -0,0 +1,212 @@
+package i18n
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/QunQunLab/ego/conf"
+)
+
+var pluralCategories = map[string]bool{
+	"zero": true, "one": true, "two": true, "few": true, "many": true, "other": true,
+}
+
+// parseJSON parse the json catalog, nested objects are flattened to dot
+// separated keys, an object of plural categories is a plural message
+//
+//	{"user": {"not_found": "user {name} not found"},
+//	 "cart.items": {"one": "{count} item", "other": "{count} items"}}
+func parseJSON(data []byte) (map[string]Message, error) {
+	var raw map[string]interface{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+	messages := map[string]Message{}
+	return messages, flatten(messages, "", raw)
+}
+
+func flatten(messages map[string]Message, prefix string, raw map[string]interface{}) error {
+	for k, v := range raw {
+		key := k
+		if prefix != "" {
+			key = prefix + "." + k
+		}
+		switch val := v.(type) {
+		case string:
+			messages[key] = Message{"other": val}
+		case map[string]interface{}:
+			if m, ok := pluralMessage(val); ok {
+				messages[key] = m
+			} else if err := flatten(messages, key, val); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("%v: invalid message %v", key, v)
+		}
+	}
+	return nil
+}
+
+// pluralMessage return the message if all the keys are plural categories
+func pluralMessage(raw map[string]interface{}) (Message, bool) {
+	if len(raw) == 0 {
+		return nil, false
+	}
+	m := Message{}
+	for k, v := range raw {
+		s, ok := v.(string)
+		if !ok || !pluralCategories[k] {
+			return nil, false
+		}
+		m[k] = s
+	}
+	return m, true
+}
+
+// parseYAML parse the yaml catalog by conf.DecodeYAML, nested mappings are
+// flattened like parseJSON
+//
+//	user:
+//	  not_found: "user {name} not found"
+//	cart.items:
+//	  one: '{count} item'
+//	  other: '{count} items'
+func parseYAML(data []byte) (map[string]Message, error) {
+	root, err := conf.DecodeYAML(data)
+	if err != nil {
+		return nil, err
+	}
+	messages := map[string]Message{}
+	return messages, flatten(messages, "", root)
+}
+
+// parsePO parse the gettext po catalog, msgid is the message key, and
+// msgctxt is prepended to it with a dot. msgstr[n] of plural messages are
+// the plural categories of lang in order. Fuzzy and untranslated entries
+// are skipped.
+//
+//	msgctxt "cart"
+//	msgid "items"
+//	msgid_plural "items"
+//	msgstr[0] "{count} item"
+//	msgstr[1] "{count} items"
+func parsePO(data []byte, lang string) (map[string]Message, error) {
+	messages := map[string]Message{}
+	categories := pluralOf(normalize(lang)).categories
+
+	var (
+		ctxt, id string
+		strs     map[int]string
+		fuzzy    bool
+		// the string continued by the following "..." lines
+		current func(string)
+	)
+	// flush add the entry, an entry ends at the first comment,
+	// msgctxt or msgid after its msgstr
+	flush := func() {
+		if id != "" && !fuzzy {
+			key := id
+			if ctxt != "" {
+				key = ctxt + "." + id
+			}
+			m := Message{}
+			for i, str := range strs {
+				if str == "" {
+					continue
+				}
+				if i < 0 {
+					m["other"] = str
+				} else if i < len(categories) {
+					m[categories[i]] = str
+				}
+			}
+			if len(m) > 0 {
+				messages[key] = m
+			}
+		}
+		ctxt, id, strs, fuzzy, current = "", "", nil, false, nil
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			if strs != nil {
+				flush()
+			}
+			continue
+		}
+		if line[0] == '#' {
+			if strs != nil {
+				flush()
+			}
+			if strings.HasPrefix(line, "#,") && strings.Contains(line, "fuzzy") {
+				fuzzy = true
+			}
+			continue
+		}
+		if line[0] == '"' {
+			str, err := strconv.Unquote(line)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %v", lineNo, err)
+			}
+			if current == nil {
+				return nil, fmt.Errorf("line %d: unexpected string", lineNo)
+			}
+			current(str)
+			continue
+		}
+
+		keyword, value := line, ""
+		if i := strings.IndexAny(line, " \t"); i > 0 {
+			keyword, value = line[:i], strings.TrimSpace(line[i:])
+		}
+		str, err := strconv.Unquote(value)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", lineNo, err)
+		}
+
+		switch {
+		case keyword == "msgctxt":
+			if strs != nil {
+				flush()
+			}
+			ctxt = str
+			current = func(s string) { ctxt += s }
+		case keyword == "msgid":
+			if strs != nil {
+				flush()
+			}
+			id = str
+			current = func(s string) { id += s }
+		case keyword == "msgid_plural":
+			// the plural forms are in msgstr[n]
+			current = func(string) {}
+		case keyword == "msgstr" || strings.HasPrefix(keyword, "msgstr[") && strings.HasSuffix(keyword, "]"):
+			n := -1
+			if keyword != "msgstr" {
+				if n, err = strconv.Atoi(keyword[7 : len(keyword)-1]); err != nil || n < 0 {
+					return nil, fmt.Errorf("line %d: invalid %v", lineNo, keyword)
+				}
+			}
+			if strs == nil {
+				strs = map[int]string{}
+			}
+			strs[n] = str
+			current = func(s string) { strs[n] += s }
+		default:
+			return nil, fmt.Errorf("line %d: unknown keyword %v", lineNo, keyword)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	flush()
+	return messages, nil
+}
@@ -0,0 +1,120 @@
+package i18n
+
+import (
+	"strings"
+	"sync"
+)
+
+// PluralRule return the plural category of n
+type PluralRule func(n int64) string
+
+type plural struct {
+	// categories in the order of po msgstr[n]
+	categories []string
+	rule       PluralRule
+}
+
+var (
+	pluralsMu sync.RWMutex
+	plurals   = map[string]*plural{}
+
+	defaultPlural = &plural{[]string{"one", "other"}, oneOther}
+)
+
+func init() {
+	for _, lang := range []string{"cn", "zh", "ja", "ko", "vi", "th", "id", "ms"} {
+		RegisterPlural(lang, []string{"other"}, func(int64) string { return "other" })
+	}
+	for _, lang := range []string{"fr", "pt"} {
+		RegisterPlural(lang, []string{"one", "other"}, func(n int64) string {
+			if n == 0 || n == 1 {
+				return "one"
+			}
+			return "other"
+		})
+	}
+	for _, lang := range []string{"ru", "uk", "be"} {
+		RegisterPlural(lang, []string{"one", "few", "many"}, func(n int64) string {
+			switch {
+			case n%10 == 1 && n%100 != 11:
+				return "one"
+			case n%10 >= 2 && n%10 <= 4 && (n%100 < 12 || n%100 > 14):
+				return "few"
+			}
+			return "many"
+		})
+	}
+	RegisterPlural("pl", []string{"one", "few", "many"}, func(n int64) string {
+		switch {
+		case n == 1:
+			return "one"
+		case n%10 >= 2 && n%10 <= 4 && (n%100 < 12 || n%100 > 14):
+			return "few"
+		}
+		return "many"
+	})
+	for _, lang := range []string{"cs", "sk"} {
+		RegisterPlural(lang, []string{"one", "few", "other"}, func(n int64) string {
+			switch {
+			case n == 1:
+				return "one"
+			case n >= 2 && n <= 4:
+				return "few"
+			}
+			return "other"
+		})
+	}
+	RegisterPlural("ar", []string{"zero", "one", "two", "few", "many", "other"}, func(n int64) string {
+		switch {
+		case n == 0:
+			return "zero"
+		case n == 1:
+			return "one"
+		case n == 2:
+			return "two"
+		case n%100 >= 3 && n%100 <= 10:
+			return "few"
+		case n%100 >= 11:
+			return "many"
+		}
+		return "other"
+	})
+}
+
+// RegisterPlural register the plural rule of a language, categories are
+// the plural categories in the order of the po msgstr[n]. Languages not
+// registered use the english rule, one for 1 and other for the rest.
+func RegisterPlural(lang string, categories []string, rule PluralRule) {
+	pluralsMu.Lock()
+	defer pluralsMu.Unlock()
+	plurals[normalize(lang)] = &plural{categories, rule}
+}
+
+// pluralOf return the plural of lang or its primary language
+func pluralOf(lang string) *plural {
+	pluralsMu.RLock()
+	defer pluralsMu.RUnlock()
+	if p, ok := plurals[lang]; ok {
+		return p
+	}
+	if i := strings.IndexByte(lang, '-'); i > 0 {
+		if p, ok := plurals[lang[:i]]; ok {
+			return p
+		}
+	}
+	return defaultPlural
+}
+
+func pluralCategory(lang string, n int64) string {
+	if n < 0 {
+		n = -n
+	}
+	return pluralOf(lang).rule(n)
+}
+
+func oneOther(n int64) string {
+	if n == 1 {
+		return "one"
+	}
+	return "other"
+}
@@ -4,12 +4,15 @@ import (
 	"encoding/json"
 	"fmt"
 	"strings"
+	"sync"
 
 	"github.com/QunQunLab/ego/conf"
 	"github.com/zerak/log"
 	"github.com/zerak/log/provider"
 )
 
+var watchOnce sync.Once
+
 type LogOption struct {
 	Dir         string `json:"dir,omitempty"`          // log directory(default: .)
 	Filename    string `json:"filename,omitempty"`     // log filename(default: <appName>.log)
@@ -21,39 +24,70 @@ type LogOption struct {
 	Level       string `json:"level,omitempty"`        //level, 0:fatal 1:error 2:warn 3:info 4:debug 5:trace
 }
 
+// Init init the log by the options, or the log section of the global
+// config if no option, see InitConfig
 func Init(opts ...LogOption) error {
-
 	if len(opts) > 0 {
 		mfOpts, _ := json.Marshal(opts[0])
 		consoleOpts := fmt.Sprintf(`{"tostderrlevel":%d}`, opts[0].Level)
 		p := provider.NewMixProvider(provider.NewFile(string(mfOpts)), provider.NewColoredConsole(consoleOpts))
 		log.InitWithProvider(p)
 		log.SetLevelFromString(opts[0].Level)
-	} else {
-		var (
-			rootDir  = "./log"
-			filename = "app"
-			level    = "debug"
-			maxSize  = int64(1 << 26) // 1*2^26 = 64M
-		)
-		l := conf.Get("log")
-		if l != nil {
-			rootDir, _ = l.String("root", "./log")
-			filename, _ = l.String("name", "app")
-			level, _ = l.String("level")
-			maxSize, _ = l.Int("maxsize", 1<<26)
-		}
-		mfOpts, _ := json.Marshal(&LogOption{
-			Dir:      rootDir,
-			Filename: filename,
-			MaxSize:  int(maxSize),
-			Level:    level,
-		})
-		consoleOpts := fmt.Sprintf(`{"tostderrlevel":%s}`, level)
-		p := provider.NewMixProvider(provider.NewFile(string(mfOpts)), provider.NewColoredConsole(consoleOpts))
-		log.InitWithProvider(p)
-		log.SetLevelFromString(level)
+		return nil
 	}
+	return InitConfig(conf.Global())
+}
+
+func init() {
+	conf.RegisterSection("log")
+}
+
+// InitConfig init the log by the log section of c
+//
+//	[log]
+//	root = ./log
+//	name = app
+//	level = debug
+//	maxsize = 67108864
+func InitConfig(c *conf.Config) error {
+	var (
+		rootDir  = "./log"
+		filename = "app"
+		level    = "debug"
+		maxSize  = int64(1 << 26) // 1*2^26 = 64M
+	)
+	l := c.Get("log")
+	if l != nil {
+		rootDir, _ = l.String("root", "./log")
+		filename, _ = l.String("name", "app")
+		level, _ = l.String("level")
+		maxSize, _ = l.Int("maxsize", 1<<26)
+	}
+	mfOpts, _ := json.Marshal(&LogOption{
+		Dir:      rootDir,
+		Filename: filename,
+		MaxSize:  int(maxSize),
+		Level:    level,
+	})
+	consoleOpts := fmt.Sprintf(`{"tostderrlevel":%s}`, level)
+	p := provider.NewMixProvider(provider.NewFile(string(mfOpts)), provider.NewColoredConsole(consoleOpts))
+	log.InitWithProvider(p)
+	log.SetLevelFromString(level)
+
+	// the level is updated live by conf.Reload of the global config
+	if c != conf.Global() {
+		return nil
+	}
+	watchOnce.Do(func() {
+		conf.OnChange("log", func(old, new *conf.Section) {
+			if new == nil {
+				return
+			}
+			if level, err := new.String("level"); err == nil {
+				log.SetLevelFromString(level)
+			}
+		})
+	})
 	return nil
 }
 
@@ -19,34 +19,74 @@ var (
 )
 
 type masterOption struct {
-	Host         string `json:"mysql_master:host"`
-	User         string `json:"mysql_master:user"`
+	Host         string `json:"mysql_master:host" validate:"required"`
+	User         string `json:"mysql_master:user" validate:"required"`
 	Password     string `json:"mysql_master:password"`
-	Database     string `json:"mysql_master:database"`
+	Database     string `json:"mysql_master:database" validate:"required"`
 	Charset      string `json:"mysql_master:charset"`
-	MaxOpenConns int    `json:"mysql_master:max_open_conns"`
-	MaxIdleConns int    `json:"mysql_master:max_idle_conns"`
+	MaxOpenConns int    `json:"mysql_master:max_open_conns" validate:"min=0"`
+	MaxIdleConns int    `json:"mysql_master:max_idle_conns" validate:"min=0"`
 	Debug        bool   `json:"mysql_master:debug"`
 }
 
 type slaveOption struct {
-	Host         string `json:"mysql_slave:host"`
-	User         string `json:"mysql_slave:user"`
+	Host         string `json:"mysql_slave:host" validate:"required"`
+	User         string `json:"mysql_slave:user" validate:"required"`
 	Password     string `json:"mysql_slave:password"`
-	Database     string `json:"mysql_slave:database"`
+	Database     string `json:"mysql_slave:database" validate:"required"`
 	Charset      string `json:"mysql_slave:charset"`
-	MaxOpenConns int    `json:"mysql_slave:max_open_conns"`
-	MaxIdleConns int    `json:"mysql_slave:max_idle_conns"`
+	MaxOpenConns int    `json:"mysql_slave:max_open_conns" validate:"min=0"`
+	MaxIdleConns int    `json:"mysql_slave:max_idle_conns" validate:"min=0"`
 	Debug        bool   `json:"mysql_slave:debug"`
 }
 
-func getEngine(mysqlConf string) *xorm.Engine {
+func init() {
+	// check the mysql sections by --check-config, the engines are only
+	// built on the first query
+	conf.OnValidate(checkOptions)
+	conf.RegisterSection("mysql_master", "mysql_slave")
+}
+
+// checkOptions validate mysql_master and mysql_slave if they're configured
+func checkOptions(c *conf.Config) error {
+	var errs conf.ValidationErrors
+	for _, v := range []struct {
+		section string
+		option  interface{}
+	}{
+		{"mysql_master", &masterOption{}},
+		{"mysql_slave", &slaveOption{}},
+	} {
+		if c.Get(v.section) == nil {
+			continue
+		}
+		if err := c.Unmarshal(v.option, "json"); err != nil {
+			errs = append(errs, fmt.Errorf("%v: %v", v.section, err))
+			continue
+		}
+		if err := c.Validate(v.option, "json"); err != nil {
+			errs = append(errs, err.(conf.ValidationErrors)...)
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func getEngine(c *conf.Config, mysqlConf string) *xorm.Engine {
 	if mysqlConf == "mysql_master" {
 		master := masterOption{}
-		err := conf.Unmarshal(&master)
+		err := c.Unmarshal(&master, "json")
 		if err != nil {
 			log.Error("%v unmarshal err:%v", mysqlConf, err)
 		}
+		// checked by --check-config, the unconfigured section is skipped
+		if c.Get(mysqlConf) != nil {
+			if err = c.Validate(&master, "json"); err != nil {
+				log.Error("%v config err:%v", mysqlConf, err)
+			}
+		}
 		// [username[:password]@][protocol[(address)]]/dbname[?param1=value1&...&paramN=valueN]
 		mds := fmt.Sprintf("%v:%v@tcp(%v)/%v?charset=%v", master.User, master.Password, master.Host, master.Database, master.Charset)
 		log.Trace("%v source:%v", mysqlConf, mds)
@@ -66,10 +106,15 @@ func getEngine(mysqlConf string) *xorm.Engine {
 
 	if mysqlConf == "mysql_slave" {
 		slave := slaveOption{}
-		err := conf.Unmarshal(&slave)
+		err := c.Unmarshal(&slave, "json")
 		if err != nil {
 			panic(err)
 		}
+		if c.Get(mysqlConf) != nil {
+			if err = c.Validate(&slave, "json"); err != nil {
+				log.Error("%v config err:%v", mysqlConf, err)
+			}
+		}
 		sds := fmt.Sprintf("%v:%v@tcp(%v)/%v?charset=%v", slave.User, slave.Password, slave.Host, slave.Database, slave.Charset)
 		log.Trace("%v source:%v", mysqlConf, sds)
 		se, err := xorm.NewEngine("mysql", sds)
@@ -86,7 +131,7 @@ func getEngine(mysqlConf string) *xorm.Engine {
 		return se
 	}
 
-	section := conf.Get(mysqlConf)
+	section := c.Get(mysqlConf)
 	host, _ := section.String("host")
 	user, _ := section.String("user")
 	pass, _ := section.String("password")
@@ -111,6 +156,9 @@ func getEngine(mysqlConf string) *xorm.Engine {
 	return se
 }
 
+// Engine return the cached engine group of the global config, the master
+// and slave sections are mysql_master and mysql_slave by default, see
+// EngineConfig
 func Engine(c ...string) *xorm.EngineGroup {
 	m := "mysql_master"
 	s := "mysql_slave"
@@ -127,32 +175,40 @@ func Engine(c ...string) *xorm.EngineGroup {
 	h.Write(buf.Bytes())
 	key := hex.EncodeToString(h.Sum(nil))
 
-	if val, ok := engines[key]; ok {
+	mutex.RLock()
+	val, ok := engines[key]
+	mutex.RUnlock()
+	if ok {
 		log.Trace("get orm engine:%v", key)
 		return val
-	} else {
-		log.Trace("new orm engine:%v", key)
-		master := getEngine(m)
-
-		// slaves
-		var slaves []*xorm.Engine
-		if len(c) <= 0 {
-			slaves = append(slaves, getEngine(s))
-		} else {
-			for i := 1; i < len(c); i++ {
-				slaves = append(slaves, getEngine(c[i]))
-			}
-		}
+	}
+	log.Trace("new orm engine:%v", key)
+	eg := EngineConfig(conf.Global(), c...)
 
-		eg, err := xorm.NewEngineGroup(master, slaves)
-		if err != nil {
-			log.Fatal("engineGroup:%v err:%v", c, err)
-			panic(err)
-		}
+	mutex.Lock()
+	engines[key] = eg
+	mutex.Unlock()
+	return eg
+}
+
+// EngineConfig return a new engine group of the sections of cfg, the first
+// section is the master and the others are the slaves. It's not cached.
+func EngineConfig(cfg *conf.Config, c ...string) *xorm.EngineGroup {
+	if len(c) == 0 {
+		c = []string{"mysql_master", "mysql_slave"}
+	}
+	master := getEngine(cfg, c[0])
 
-		mutex.Lock()
-		engines[key] = eg
-		mutex.Unlock()
-		return eg
+	// slaves
+	var slaves []*xorm.Engine
+	for i := 1; i < len(c); i++ {
+		slaves = append(slaves, getEngine(cfg, c[i]))
+	}
+
+	eg, err := xorm.NewEngineGroup(master, slaves)
+	if err != nil {
+		log.Fatal("engineGroup:%v err:%v", c, err)
+		panic(err)
 	}
+	return eg
 }
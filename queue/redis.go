@@ -12,6 +12,7 @@ import (
 	"github.com/go-redis/redis"
 	"github.com/satori/go.uuid"
 
+	"github.com/QunQunLab/ego/conf"
 	"github.com/QunQunLab/ego/log"
 )
 
@@ -227,3 +228,33 @@ func (p *Producer) PublishDelayMsg(topicName string, body []byte, delay time.Dur
 func NewProducer(cmd redis.Cmdable) *Producer {
 	return &Producer{redisCmd: cmd}
 }
+
+// NewRedisClient return a redis client of the section of c for NewConsumer
+// and NewProducer
+//
+//	[redis]
+//	addr = 127.0.0.1:6379
+//	password = secret://env/REDIS_PASSWORD
+//	db = 0
+//
+// The section should be declared by conf.RegisterSection in strict mode.
+func NewRedisClient(c *conf.Config, section string) (*redis.Client, error) {
+	s := c.Get(section)
+	if s == nil {
+		return nil, fmt.Errorf("redis section %v not found", section)
+	}
+	addr, err := s.String("addr")
+	if err != nil {
+		return nil, err
+	}
+	password, _ := s.String("password")
+	db, err := s.Int("db", 0)
+	if _, ok := err.(*conf.NoKeyError); err != nil && !ok {
+		return nil, fmt.Errorf("%v:db err:%v", section, err)
+	}
+	return redis.NewClient(&redis.Options{
+		Addr:     addr,
+		Password: password,
+		DB:       int(db),
+	}), nil
+}
@@ -0,0 +1,186 @@
+package service
+
+import (
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/QunQunLab/ego/conf"
+	"github.com/QunQunLab/ego/log"
+)
+
+// loopback proxies are trusted if http_conf:trusted_proxies is not set
+const defaultTrustedProxies = "127.0.0.0/8,::1/128"
+
+var (
+	proxiesMu  sync.Mutex
+	proxiesRaw string
+	proxies    []*net.IPNet
+)
+
+// trustedProxies return the parsed http_conf:trusted_proxies of c, a comma
+// separated list of CIDRs or IPs, the result is cached until it changed.
+//
+//	[http_conf]
+//	trusted_proxies = 10.0.0.0/8,172.16.0.1,fd00::/8
+func trustedProxies(c *conf.Config) []*net.IPNet {
+	raw := defaultTrustedProxies
+	if section := c.Get("http_conf"); section != nil {
+		raw, _ = section.String("trusted_proxies", defaultTrustedProxies)
+	}
+
+	proxiesMu.Lock()
+	defer proxiesMu.Unlock()
+	if raw == proxiesRaw && proxies != nil {
+		return proxies
+	}
+	proxiesRaw = raw
+	proxies = parseCIDRs(raw)
+	return proxies
+}
+
+func parseCIDRs(raw string) []*net.IPNet {
+	nets := []*net.IPNet{}
+	for _, s := range strings.Split(raw, ",") {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+		if !strings.Contains(s, "/") {
+			if ip := net.ParseIP(s); ip != nil && ip.To4() != nil {
+				s += "/32"
+			} else {
+				s += "/128"
+			}
+		}
+		_, n, err := net.ParseCIDR(s)
+		if err != nil {
+			log.Error("http_conf:trusted_proxies invalid cidr:%v err:%v", s, err)
+			continue
+		}
+		nets = append(nets, n)
+	}
+	return nets
+}
+
+// ClientIP return the client ip address.
+//
+// The forwarding headers are used only if the request comes from a
+// trusted proxy (http_conf:trusted_proxies). The RFC 7239 Forwarded
+// header is preferred to X-Forwarded-For, the hops are walked from right
+// to left and the first untrusted one is the client.
+func (c *Controller) ClientIP() string {
+	return clientIP(c.Ctx.Request, trustedProxies(c.Config()))
+}
+
+func clientIP(r *http.Request, trusted []*net.IPNet) string {
+	remote := parseHop(r.RemoteAddr)
+	if remote == nil {
+		return r.RemoteAddr
+	}
+	if !isTrusted(remote, trusted) {
+		return remote.String()
+	}
+
+	var hops []string
+	if fwd := r.Header.Values("Forwarded"); len(fwd) > 0 {
+		hops = parseForwarded(fwd)
+	} else if xff := r.Header.Values("X-Forwarded-For"); len(xff) > 0 {
+		for _, v := range xff {
+			hops = append(hops, strings.Split(v, ",")...)
+		}
+	} else if real := r.Header.Get("X-Real-Ip"); real != "" {
+		hops = []string{real}
+	}
+
+	client := remote
+	for i := len(hops) - 1; i >= 0; i-- {
+		ip := parseHop(hops[i])
+		if ip == nil {
+			// unknown or obfuscated identifier, the last proxy is the client
+			break
+		}
+		client = ip
+		if !isTrusted(ip, trusted) {
+			break
+		}
+	}
+	return client.String()
+}
+
+// parseForwarded return the "for" parameters of the Forwarded headers
+//
+//	Forwarded: for=192.0.2.60;proto=http;by=203.0.113.43, for="[2001:db8:cafe::17]:4711"
+func parseForwarded(values []string) []string {
+	var hops []string
+	for _, v := range values {
+		for _, element := range splitQuoted(v, ',') {
+			for _, pair := range splitQuoted(element, ';') {
+				idx := strings.Index(pair, "=")
+				if idx < 0 {
+					continue
+				}
+				if strings.EqualFold(strings.TrimSpace(pair[:idx]), "for") {
+					hops = append(hops, strings.TrimSpace(pair[idx+1:]))
+				}
+			}
+		}
+	}
+	return hops
+}
+
+// splitQuoted split s by sep outside of the quoted strings
+func splitQuoted(s string, sep byte) []string {
+	var (
+		parts  []string
+		quoted bool
+		start  int
+	)
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '"':
+			quoted = !quoted
+		case '\\':
+			if quoted {
+				i++
+			}
+		case sep:
+			if !quoted {
+				parts = append(parts, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(parts, s[start:])
+}
+
+// parseHop parse an ip with optional port, such as "192.0.2.1",
+// "192.0.2.1:80", "2001:db8::1", "[2001:db8::1]:80" and the quoted ones
+func parseHop(s string) net.IP {
+	s = strings.TrimSpace(s)
+	s = strings.Trim(s, `"`)
+	if strings.HasPrefix(s, "[") {
+		idx := strings.Index(s, "]")
+		if idx < 0 {
+			return nil
+		}
+		s = s[1:idx]
+	} else if strings.Count(s, ":") == 1 {
+		s = s[:strings.Index(s, ":")]
+	}
+	// zone of link local addresses
+	if idx := strings.Index(s, "%"); idx >= 0 {
+		s = s[:idx]
+	}
+	return net.ParseIP(s)
+}
+
+func isTrusted(ip net.IP, trusted []*net.IPNet) bool {
+	for _, n := range trusted {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,41 @@
+package service
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientIP(t *testing.T) {
+	trusted := parseCIDRs("10.0.0.0/8, 127.0.0.1, fd00::/8")
+	cases := []struct {
+		remote string
+		header map[string]string
+		want   string
+	}{
+		// untrusted remote, headers are ignored
+		{"203.0.113.9:1234", map[string]string{"X-Forwarded-For": "1.1.1.1"}, "203.0.113.9"},
+		{"[2001:db8::1]:443", nil, "2001:db8::1"},
+		// walk right to left, stop at the first untrusted hop
+		{"10.0.0.1:80", map[string]string{"X-Forwarded-For": "6.6.6.6, 192.0.2.1, 10.0.0.2"}, "192.0.2.1"},
+		{"10.0.0.1:80", map[string]string{"X-Forwarded-For": "2001:db8::2, 10.0.0.2"}, "2001:db8::2"},
+		{"127.0.0.1:80", map[string]string{"X-Forwarded-For": "10.0.0.3"}, "10.0.0.3"},
+		{"127.0.0.1:80", map[string]string{"X-Real-Ip": "192.0.2.7"}, "192.0.2.7"},
+		{"127.0.0.1:80", map[string]string{"X-Forwarded-For": "unknown"}, "127.0.0.1"},
+		// Forwarded is preferred
+		{"[fd00::1]:80", map[string]string{
+			"Forwarded":       `for=192.0.2.60;proto=http, for="[2001:db8:cafe::17]:4711";by=10.0.0.1`,
+			"X-Forwarded-For": "6.6.6.6",
+		}, "2001:db8:cafe::17"},
+		{"10.0.0.1:80", map[string]string{"Forwarded": `For="192.0.2.43:8080", for=10.1.1.1`}, "192.0.2.43"},
+	}
+	for _, cs := range cases {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.RemoteAddr = cs.remote
+		for k, v := range cs.header {
+			r.Header.Set(k, v)
+		}
+		if got := clientIP(r, trusted); got != cs.want {
+			t.Errorf("remote:%v header:%v got %v, want %v", cs.remote, cs.header, got, cs.want)
+		}
+	}
+}
@@ -0,0 +1,317 @@
+package service
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"errors"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	encodingGzip    = "gzip"
+	encodingDeflate = "deflate"
+)
+
+var defaultCompressTypes = []string{
+	"text/html",
+	"text/plain",
+	"text/css",
+	"text/xml",
+	"text/javascript",
+	"application/javascript",
+	"application/json",
+	"application/xml",
+	"image/svg+xml",
+}
+
+// CompressOptions response compression options
+type CompressOptions struct {
+	// compress level, see compress/flate (default: flate.DefaultCompression).
+	// 0 is the default, flate.NoCompression is not supported as the
+	// middleware is not needed then
+	Level int
+	// responses smaller than MinSize bytes are sent as is (default: 1024)
+	MinSize int
+	// media types allowed to be compressed (default: text, json, xml, js, css, svg)
+	ContentTypes []string
+}
+
+// compressor is implemented by both *gzip.Writer and *flate.Writer
+type compressor interface {
+	io.WriteCloser
+	Reset(w io.Writer)
+	Flush() error
+}
+
+// Compress return a middleware compress responses by gzip or deflate,
+// negotiated by the Accept-Encoding request header.
+//
+//	s := service.NewHttpService()
+//	s.Use(service.Compress(service.CompressOptions{MinSize: 512}))
+func Compress(op ...CompressOptions) Middleware {
+	opt := CompressOptions{
+		Level:        flate.DefaultCompression,
+		MinSize:      1024,
+		ContentTypes: defaultCompressTypes,
+	}
+	if len(op) > 0 {
+		if op[0].Level != 0 {
+			opt.Level = op[0].Level
+		}
+		if op[0].MinSize > 0 {
+			opt.MinSize = op[0].MinSize
+		}
+		if len(op[0].ContentTypes) > 0 {
+			opt.ContentTypes = op[0].ContentTypes
+		}
+	}
+
+	// check level once, then the pools never fail to create a writer
+	if _, err := gzip.NewWriterLevel(io.Discard, opt.Level); err != nil {
+		panic(err)
+	}
+
+	pools := map[string]*sync.Pool{
+		encodingGzip: {New: func() interface{} {
+			w, _ := gzip.NewWriterLevel(io.Discard, opt.Level)
+			return w
+		}},
+		encodingDeflate: {New: func() interface{} {
+			w, _ := flate.NewWriter(io.Discard, opt.Level)
+			return w
+		}},
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// websocket and other upgraded connections are never compressed
+			if r.Header.Get("Upgrade") != "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			cw := &compressWriter{
+				ResponseWriter: w,
+				opt:            &opt,
+				pools:          pools,
+				encoding:       negotiateEncoding(r.Header.Get("Accept-Encoding")),
+				head:           r.Method == http.MethodHead,
+			}
+			defer cw.close()
+			next.ServeHTTP(cw, r)
+		})
+	}
+}
+
+// negotiateEncoding choose gzip or deflate from Accept-Encoding by q-value,
+// return "" if none is acceptable.
+func negotiateEncoding(accept string) string {
+	var (
+		best  string
+		bestQ float64
+		star  = -1.0
+		seen  = map[string]float64{}
+	)
+	for _, part := range strings.Split(accept, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		q := 1.0
+		if idx := strings.Index(part, ";"); idx >= 0 {
+			param := strings.TrimSpace(part[idx+1:])
+			part = strings.TrimSpace(part[:idx])
+			if strings.HasPrefix(param, "q=") {
+				if v, err := strconv.ParseFloat(param[2:], 64); err == nil {
+					q = v
+				}
+			}
+		}
+		coding := strings.ToLower(part)
+		if coding == "*" {
+			star = q
+			continue
+		}
+		seen[coding] = q
+	}
+
+	for _, coding := range []string{encodingGzip, encodingDeflate} {
+		q, ok := seen[coding]
+		if !ok {
+			q = star
+		}
+		if q > bestQ {
+			best, bestQ = coding, q
+		}
+	}
+	return best
+}
+
+// compressWriter buffer the response until MinSize bytes or a flush,
+// then decide whether the response should be compressed
+type compressWriter struct {
+	http.ResponseWriter
+
+	opt      *CompressOptions
+	pools    map[string]*sync.Pool
+	encoding string
+	head     bool
+
+	status  int
+	buf     []byte
+	decided bool
+	cw      compressor
+}
+
+func (w *compressWriter) WriteHeader(code int) {
+	if w.decided || w.status != 0 {
+		return
+	}
+	if code >= 100 && code < 200 && code != http.StatusSwitchingProtocols {
+		w.ResponseWriter.WriteHeader(code)
+		return
+	}
+	w.status = code
+	if !bodyAllowed(code) {
+		w.decide(false)
+	}
+}
+
+func (w *compressWriter) Write(p []byte) (int, error) {
+	if w.status == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.decided {
+		if w.cw != nil {
+			return w.cw.Write(p)
+		}
+		return w.ResponseWriter.Write(p)
+	}
+
+	w.buf = append(w.buf, p...)
+	if len(w.buf) >= w.opt.MinSize {
+		if err := w.decide(true); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// Flush send the buffered data to client, implement http.Flusher
+// so streaming responses keep working behind the middleware
+func (w *compressWriter) Flush() {
+	if !w.decided {
+		if w.status == 0 {
+			w.WriteHeader(http.StatusOK)
+		}
+		w.decide(true)
+	}
+	if w.cw != nil {
+		w.cw.Flush()
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack implement http.Hijacker
+func (w *compressWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if h, ok := w.ResponseWriter.(http.Hijacker); ok {
+		w.decided = true
+		return h.Hijack()
+	}
+	return nil, nil, errors.New("http.Hijacker is not implemented by the ResponseWriter")
+}
+
+// Unwrap is used by http.ResponseController
+func (w *compressWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// decide write the header and the buffered body,
+// enough is true if the body is large enough or being streamed
+func (w *compressWriter) decide(enough bool) error {
+	w.decided = true
+
+	h := w.Header()
+	if w.status != 0 && bodyAllowed(w.status) && h.Get("Content-Encoding") == "" {
+		if h.Get("Content-Type") == "" && len(w.buf) > 0 {
+			h.Set("Content-Type", http.DetectContentType(w.buf))
+		}
+		if w.compressible(h.Get("Content-Type")) {
+			addVary(h, "Accept-Encoding")
+			if enough && w.encoding != "" && !w.head {
+				h.Set("Content-Encoding", w.encoding)
+				h.Del("Content-Length")
+				w.cw = w.pools[w.encoding].Get().(compressor)
+				w.cw.Reset(w.ResponseWriter)
+			}
+		}
+	}
+
+	if w.status != 0 {
+		w.ResponseWriter.WriteHeader(w.status)
+	}
+	if len(w.buf) == 0 {
+		return nil
+	}
+
+	var err error
+	if w.cw != nil {
+		_, err = w.cw.Write(w.buf)
+	} else {
+		_, err = w.ResponseWriter.Write(w.buf)
+	}
+	w.buf = nil
+	return err
+}
+
+func (w *compressWriter) compressible(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	for _, t := range w.opt.ContentTypes {
+		if strings.EqualFold(t, mediaType) {
+			return true
+		}
+	}
+	return false
+}
+
+// close flush the remaining data and put the compressor back to pool
+func (w *compressWriter) close() {
+	if !w.decided {
+		w.decide(false)
+	}
+	if w.cw != nil {
+		w.cw.Close()
+		w.cw.Reset(io.Discard)
+		w.pools[w.encoding].Put(w.cw)
+		w.cw = nil
+	}
+}
+
+func bodyAllowed(status int) bool {
+	return status != http.StatusNoContent && status != http.StatusNotModified &&
+		(status < 100 || status >= 200)
+}
+
+func addVary(h http.Header, value string) {
+	for _, v := range h.Values("Vary") {
+		for _, f := range strings.Split(v, ",") {
+			f = strings.TrimSpace(f)
+			if f == "*" || strings.EqualFold(f, value) {
+				return
+			}
+		}
+	}
+	h.Add("Vary", value)
+}
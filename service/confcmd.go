@@ -0,0 +1,95 @@
+package service
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/QunQunLab/ego/conf"
+)
+
+// the flags of the config commands run by Run without starting the services
+const (
+	// check the config by conf.Check, the exit code is 1 if it failed
+	CheckConfigFlag = "--check-config"
+	// print the effective config and where each value is from, the format
+	// is conf by default, such as --dump-config=yaml
+	DumpConfigFlag = "--dump-config"
+	// print the changes from the config to the file, such as
+	// --diff-config=app.new.conf
+	DiffConfigFlag = "--diff-config"
+)
+
+// ConfigCommand run the config command of args on the global config, it
+// returns the exit code and whether any command is run
+func ConfigCommand(args []string, stdout, stderr io.Writer) (int, bool) {
+	return ConfigCommandOf(conf.Global(), args, stdout, stderr)
+}
+
+// ConfigCommandOf run the config command of args on c, see ConfigCommand
+func ConfigCommandOf(c *conf.Config, args []string, stdout, stderr io.Writer) (int, bool) {
+	if _, ok := argValue(args, CheckConfigFlag); ok {
+		return checkConfig(c, stdout, stderr), true
+	}
+	if format, ok := argValue(args, DumpConfigFlag); ok {
+		if format == "" {
+			format = conf.FormatConf
+		}
+		if err := c.Dump(stdout, format, conf.DumpOptions{Sources: true}); err != nil {
+			fmt.Fprintf(stderr, "dump config err:%v\n", err)
+			return 1, true
+		}
+		return 0, true
+	}
+	if file, ok := argValue(args, DiffConfigFlag); ok {
+		if file == "" {
+			fmt.Fprintf(stderr, "usage: %s=<file>\n", DiffConfigFlag)
+			return 2, true
+		}
+		changes, err := c.DiffFile(file)
+		if err != nil {
+			fmt.Fprintf(stderr, "diff config err:%v\n", err)
+			return 1, true
+		}
+		for _, ch := range changes {
+			fmt.Fprintln(stdout, ch)
+		}
+		return 0, true
+	}
+	return 0, false
+}
+
+// checkConfig print the problems of c, return the exit code
+func checkConfig(c *conf.Config, stdout, stderr io.Writer) int {
+	err := c.Check()
+	if err == nil {
+		fmt.Fprintln(stdout, "config ok")
+		return 0
+	}
+	if errs, ok := err.(conf.ValidationErrors); ok {
+		for _, e := range errs {
+			fmt.Fprintln(stderr, e)
+		}
+		fmt.Fprintf(stderr, "config check failed: %d problems\n", len(errs))
+	} else {
+		fmt.Fprintf(stderr, "config check failed: %v\n", err)
+	}
+	return 1
+}
+
+// argValue return the value of the flag "--flag" or "--flag=value" in args
+// before "--"
+func argValue(args []string, flag string) (string, bool) {
+	for _, arg := range args {
+		if arg == "--" {
+			break
+		}
+		if arg == flag {
+			return "", true
+		}
+		if strings.HasPrefix(arg, flag+"=") {
+			return arg[len(flag)+1:], true
+		}
+	}
+	return "", false
+}
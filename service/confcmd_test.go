@@ -0,0 +1,72 @@
+package service
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/QunQunLab/ego/conf"
+)
+
+func TestConfigCommand(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "app.conf")
+	next := filepath.Join(dir, "next.conf")
+	if err := os.WriteFile(file, []byte("[check_conf]\naddr = :80\npassword = secret://env/EGO_TEST_PASSWORD\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(next, []byte("[check_conf]\naddr = :8080\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("EGO_TEST_PASSWORD", "p")
+	conf.Init(file)
+
+	run := func(args ...string) (int, string, string) {
+		var stdout, stderr bytes.Buffer
+		code, ok := ConfigCommand(args, &stdout, &stderr)
+		if !ok {
+			t.Fatalf("%v is not run", args)
+		}
+		return code, stdout.String(), stderr.String()
+	}
+
+	if _, ok := ConfigCommand([]string{"-v", "--", CheckConfigFlag}, nil, nil); ok {
+		t.Error("run the command after --")
+	}
+	if code, stdout, _ := run(CheckConfigFlag); code != 0 || stdout != "config ok\n" {
+		t.Errorf("code = %v, stdout = %q", code, stdout)
+	}
+
+	code, stdout, _ := run(DumpConfigFlag)
+	want := "[check_conf]\n# " + file + ":2\naddr = :80\n# " + file + ":3\npassword = " + conf.Mask + "\n"
+	if code != 0 || stdout != want {
+		t.Errorf("code = %v, dump = %q", code, stdout)
+	}
+	if code, _, stderr := run(DumpConfigFlag + "=xml"); code != 1 || !strings.Contains(stderr, "unknown config format") {
+		t.Errorf("code = %v, stderr = %q", code, stderr)
+	}
+
+	code, stdout, _ = run(DiffConfigFlag + "=" + next)
+	if code != 0 || stdout != "~ check_conf:addr = :80 -> :8080\n- check_conf:password = ******\n" {
+		t.Errorf("code = %v, diff = %q", code, stdout)
+	}
+
+	type schema struct {
+		Addr string `json:"check_conf:host" validate:"required"`
+	}
+	conf.RegisterSchema(&schema{})
+	if code, _, stderr := run(CheckConfigFlag); code != 1 || !strings.Contains(stderr, "check_conf:host is required") {
+		t.Errorf("code = %v, stderr = %q", code, stderr)
+	}
+}
+
+func TestConfigCommandOf(t *testing.T) {
+	c := conf.FromMap(map[string]map[string]string{"app": {"name": "ego"}})
+	var stdout bytes.Buffer
+	code, ok := ConfigCommandOf(c, []string{DumpConfigFlag + "=json"}, &stdout, &stdout)
+	if !ok || code != 0 || !strings.Contains(stdout.String(), `"value": "ego"`) {
+		t.Errorf("code = %v, dump = %s", code, stdout.String())
+	}
+}
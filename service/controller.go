@@ -1,13 +1,20 @@
 package service
 
 import (
+	"context"
 	"fmt"
+	"io"
+	"mime"
 	"mime/multipart"
 	"net/http"
 	"net/url"
 	"strconv"
 	"strings"
 	"time"
+
+	"github.com/QunQunLab/ego/conf"
+	"github.com/QunQunLab/ego/session"
+	"github.com/QunQunLab/ego/websocket"
 )
 
 // Context
@@ -17,6 +24,8 @@ type Context struct {
 
 	ReqMethod string
 	S         time.Time
+	// negotiated language of the request, see http_conf:languages
+	Lang string
 }
 
 func (ctx *Context) Reset(rw http.ResponseWriter, r *http.Request) {
@@ -24,11 +33,38 @@ func (ctx *Context) Reset(rw http.ResponseWriter, r *http.Request) {
 	ctx.Request = r
 }
 
+// RequestWrap wrap the request form, which is parsed lazily on first
+// access by the Get methods of Controller or Controller.ParseForm
 type RequestWrap struct {
 	Form url.Values
+
+	req    *http.Request
+	parsed bool
+	err    error
+}
+
+// parse parse the query and the body, a multipart body is parsed only
+// if the content type is multipart/form-data, other bodies like json
+// are left untouched
+func (r *RequestWrap) parse() error {
+	if r.parsed || r.req == nil {
+		return r.err
+	}
+	r.parsed = true
+
+	mediaType, _, _ := mime.ParseMediaType(r.req.Header.Get("Content-Type"))
+	if mediaType == "multipart/form-data" {
+		r.err = r.req.ParseMultipartForm(multipartMemory(requestConfig(r.req)))
+	} else {
+		r.err = r.req.ParseForm()
+	}
+	r.err = bodyError(r.err)
+	r.Form = r.req.Form
+	return r.err
 }
 
 func (r *RequestWrap) FormValue(key string) string {
+	r.parse()
 	if vs := r.Form[key]; len(vs) > 0 {
 		return vs[0]
 	}
@@ -43,9 +79,17 @@ type ControllerInterface interface {
 	RenderError(interface{})
 }
 
+// finisher is implemented by the controllers cleaning up after the
+// controller method returned, such as Controller
+type finisher interface {
+	Finish()
+}
+
 type Controller struct {
 	Ctx   *Context
 	RWrap *RequestWrap
+
+	sse *SSE
 }
 
 func (c *Controller) Prepare(ctx *Context) {
@@ -53,9 +97,22 @@ func (c *Controller) Prepare(ctx *Context) {
 	c.Ctx.S = time.Now()
 	c.Ctx.ResponseWriter = ctx.ResponseWriter
 	c.Ctx.Request = ctx.Request
-	c.Ctx.Request.ParseMultipartForm(32 << 20) //32M
 
-	c.RWrap = &RequestWrap{ctx.Request.Form}
+	c.RWrap = &RequestWrap{req: ctx.Request}
+}
+
+// ParseForm parse the request form, it's called by the Get methods,
+// which abort the request with 413 if the body is too large
+func (c *Controller) ParseForm() error {
+	return c.RWrap.parse()
+}
+
+// form return the parsed form
+func (c *Controller) form() url.Values {
+	if err := c.RWrap.parse(); err == ErrBodyTooLarge {
+		panic(ErrBodyTooLarge)
+	}
+	return c.RWrap.Form
 }
 
 func (c *Controller) BeforeProcess() {
@@ -64,7 +121,85 @@ func (c *Controller) BeforeProcess() {
 func (c *Controller) Render(...interface{}) {
 }
 
-func (c *Controller) RenderError(interface{}) {
+// Finish is called after the controller method returned
+func (c *Controller) Finish() {
+	if c.sse != nil {
+		c.sse.Close()
+	}
+}
+
+// SSE start a server-sent events response,
+// the heartbeat interval is http_conf:sse_heartbeat (default 15s, 0 disable)
+func (c *Controller) SSE() *SSE {
+	if c.sse != nil {
+		return c.sse
+	}
+
+	heartbeat := defaultHeartbeat
+	if section := c.Config().Get("http_conf"); section != nil {
+		heartbeat, _ = section.Duration("sse_heartbeat", defaultHeartbeat)
+	}
+	sse, err := newSSE(c.Ctx.Request.Context(), c.Ctx.ResponseWriter, heartbeat)
+	if err != nil {
+		panic(err)
+	}
+	c.sse = sse
+	return sse
+}
+
+// Stream send a chunked response, step is called until it returns false
+// or the client disconnected, the response is flushed after every step.
+// Return true if the client disconnected in the middle of the stream.
+func (c *Controller) Stream(step func(w io.Writer) bool) bool {
+	done := c.Ctx.Request.Context().Done()
+	for {
+		select {
+		case <-done:
+			return true
+		default:
+			keep := step(c.Ctx.ResponseWriter)
+			c.Flush()
+			if !keep {
+				return false
+			}
+		}
+	}
+}
+
+// WebSocket upgrade the request to a WebSocket connection, a http error
+// is replied if the handshake fails. The connection is not closed when
+// the controller method returns, so it can be handed to a websocket.Hub.
+func (c *Controller) WebSocket(op ...websocket.Options) (*websocket.Conn, error) {
+	return websocket.Upgrade(c.Ctx.ResponseWriter, c.Ctx.Request, op...)
+}
+
+// Flush send any buffered data to the client
+func (c *Controller) Flush() {
+	if f, ok := c.Ctx.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Config return the config of the service, see ServiceOptions.Config
+func (c *Controller) Config() *conf.Config {
+	return requestConfig(c.Ctx.Request)
+}
+
+// Context return the request context, it's canceled when the client
+// disconnected or the timeout of the method exceeded.
+// Pass it to the orm, queue and rpc calls to stop the work in time
+func (c *Controller) Context() context.Context {
+	return c.Ctx.Request.Context()
+}
+
+// Session return the session of the request, it panics with
+// session.ErrNoSession if the session middleware is not used
+func (c *Controller) Session() *session.Session {
+	s := session.FromContext(c.Context())
+	if s == nil {
+		panic(session.ErrNoSession)
+	}
+	return s
 }
 
 func (c *Controller) GetCookie(key string) string {
@@ -80,6 +215,7 @@ func (c *Controller) GetHeader(key string) string {
 }
 
 func (c *Controller) _getFormValue(key string) string {
+	c.form()
 	val := c.RWrap.FormValue(key)
 	return strings.Trim(val, " \r\t\v")
 }
@@ -134,12 +270,13 @@ func (c *Controller) GetSliceInt(key string, separators ...string) []int {
 }
 
 func (c *Controller) GetParams() map[string]string {
-	if c.RWrap.Form == nil {
+	form := c.form()
+	if form == nil {
 		return nil
 	}
 
 	params := map[string]string{}
-	for k, v := range c.RWrap.Form {
+	for k, v := range form {
 		if len(v) > 0 {
 			params[k] = strings.Trim(v[0], " \r\t\v")
 		}
@@ -149,10 +286,11 @@ func (c *Controller) GetParams() map[string]string {
 }
 
 func (c *Controller) GetArray(key string) []string {
-	if c.RWrap.Form == nil {
+	form := c.form()
+	if form == nil {
 		return nil
 	}
-	vs := c.RWrap.Form[key]
+	vs := form[key]
 	return vs
 }
 
@@ -199,36 +337,17 @@ func (c *Controller) GetBool(key string, defaultValues ...bool) bool {
 }
 
 func (c *Controller) GetFile(key string) (multipart.File, *multipart.FileHeader, error) {
+	if err := c.ParseForm(); err != nil {
+		return nil, nil, err
+	}
 	return c.Ctx.Request.FormFile(key)
 }
 
+// GetIp return the client ip address
+//
+// Deprecated: use ClientIP
 func (c *Controller) GetIp() string {
-	r := c.Ctx.Request
-
-	ip := r.Header.Get("X-Forwarded-For")
-	if ip == "" || ip == "127.0.0.1" {
-		ip = r.Header.Get("X-Real-IP")
-		if ip == "" {
-			ip = r.Header.Get("Host")
-			if ip == "" {
-				ip = r.RemoteAddr
-			}
-		}
-	} else {
-		//X-Forwarded-For 的格式 client1, proxy1, proxy2
-		ips := strings.Split(ip, ",")
-		if len(ips) > 0 {
-			ip = ips[0]
-		}
-	}
-
-	//去除端口号
-	ips := strings.Split(ip, ":")
-	if len(ips) > 0 {
-		ip = ips[0]
-	}
-
-	return ip
+	return c.ClientIP()
 }
 
 func (c *Controller) GetRequestUri() string {
@@ -246,7 +365,7 @@ func (c *Controller) GetUA() string {
 	return ""
 }
 
-func (c *Controller) SetCookie(key, val string, lifetime int) {
+func (c *Controller) SetCookie(key, val string, lifetime int, op ...CookieOption) {
 	cookie := &http.Cookie{
 		Name:     key,
 		Value:    val,
@@ -255,18 +374,24 @@ func (c *Controller) SetCookie(key, val string, lifetime int) {
 		MaxAge:   lifetime,
 		Expires:  time.Now().Add(time.Second * time.Duration(lifetime)),
 	}
+	for _, o := range op {
+		o(cookie)
+	}
 	http.SetCookie(c.Ctx.ResponseWriter, cookie)
 }
 
-func (c *Controller) UnsetCookie(key string) {
+func (c *Controller) UnsetCookie(key string, op ...CookieOption) {
 	cookie := &http.Cookie{
 		Name:     key,
 		Value:    "",
 		Path:     "/",
 		HttpOnly: false,
-		MaxAge:   0,
+		MaxAge:   -1,
 		Expires:  time.Now().AddDate(-1, 0, 0),
 	}
+	for _, o := range op {
+		o(cookie)
+	}
 	http.SetCookie(c.Ctx.ResponseWriter, cookie)
 }
 
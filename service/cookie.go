@@ -0,0 +1,239 @@
+package service
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/QunQunLab/ego/conf"
+)
+
+var (
+	ErrCookieNoKey   = errors.New("cookie:hash_keys config is undefined")
+	ErrCookieInvalid = errors.New("cookie value is invalid")
+	ErrCookieExpired = errors.New("cookie value is expired")
+)
+
+// CookieOption set the attributes of a cookie
+type CookieOption func(*http.Cookie)
+
+// CookiePath set the cookie path (default: /)
+func CookiePath(path string) CookieOption {
+	return func(c *http.Cookie) {
+		c.Path = path
+	}
+}
+
+// CookieDomain set the cookie domain
+func CookieDomain(domain string) CookieOption {
+	return func(c *http.Cookie) {
+		c.Domain = domain
+	}
+}
+
+// CookieSecure set the cookie to be sent over https only
+func CookieSecure(secure bool) CookieOption {
+	return func(c *http.Cookie) {
+		c.Secure = secure
+	}
+}
+
+// CookieHttpOnly set the cookie not accessible to javascript
+func CookieHttpOnly(httpOnly bool) CookieOption {
+	return func(c *http.Cookie) {
+		c.HttpOnly = httpOnly
+	}
+}
+
+// CookieSameSite set the SameSite attribute of the cookie
+func CookieSameSite(sameSite http.SameSite) CookieOption {
+	return func(c *http.Cookie) {
+		c.SameSite = sameSite
+	}
+}
+
+// SetSecureCookie set a cookie signed by HMAC-SHA256, and encrypted by
+// AES-GCM if cookie:block_keys is set. The first key signs or encrypts,
+// all the keys are tried to decode, so keys can be rotated by prepending
+// a new one. Secure cookies are HttpOnly, SameSite=Lax and Secure for
+// https requests or cookie:secure=true by default.
+//
+//	[cookie]
+//	hash_keys = new-hash-secret,old-hash-secret
+//	block_keys = new-block-secret,old-block-secret
+//	secure = true
+func (c *Controller) SetSecureCookie(key, val string, lifetime int, op ...CookieOption) error {
+	var expires time.Time
+	if lifetime > 0 {
+		expires = time.Now().Add(time.Second * time.Duration(lifetime))
+	}
+	encoded, err := encodeCookie(loadCookieKeys(c.Config()), key, val, expires)
+	if err != nil {
+		return err
+	}
+
+	secure := c.Ctx.Request.TLS != nil
+	if section := c.Config().Get("cookie"); section != nil && !secure {
+		secure, _ = section.Bool("secure", false)
+	}
+	cookie := &http.Cookie{
+		Name:     key,
+		Value:    encoded,
+		Path:     "/",
+		HttpOnly: true,
+		Secure:   secure,
+		SameSite: http.SameSiteLaxMode,
+		MaxAge:   lifetime,
+		Expires:  expires,
+	}
+	for _, o := range op {
+		o(cookie)
+	}
+	http.SetCookie(c.Ctx.ResponseWriter, cookie)
+	return nil
+}
+
+// GetSecureCookie return the value of a cookie set by SetSecureCookie,
+// an error is returned if the cookie is missing, tampered or expired
+func (c *Controller) GetSecureCookie(key string) (string, error) {
+	cookie, err := c.Ctx.Request.Cookie(key)
+	if err != nil {
+		return "", err
+	}
+	return decodeCookie(loadCookieKeys(c.Config()), key, cookie.Value)
+}
+
+// cookieKeys the keys to sign and encrypt cookies, the first one is used
+// to encode, all of them are tried to decode
+type cookieKeys struct {
+	hash  [][]byte
+	block []cipher.AEAD
+}
+
+func loadCookieKeys(c *conf.Config) *cookieKeys {
+	keys := &cookieKeys{}
+	section := c.Get("cookie")
+	if section == nil {
+		return keys
+	}
+
+	hashKeys, _ := section.Strings("hash_keys")
+	for _, k := range hashKeys {
+		if k = strings.TrimSpace(k); k != "" {
+			keys.hash = append(keys.hash, []byte(k))
+		}
+	}
+	blockKeys, _ := section.Strings("block_keys")
+	for _, k := range blockKeys {
+		if k = strings.TrimSpace(k); k != "" {
+			keys.block = append(keys.block, newAEAD(k))
+		}
+	}
+	return keys
+}
+
+// newAEAD return an AES-256-GCM with the key derived from secret
+func newAEAD(secret string) cipher.AEAD {
+	key := sha256.Sum256([]byte(secret))
+	block, _ := aes.NewCipher(key[:])
+	aead, _ := cipher.NewGCM(block)
+	return aead
+}
+
+// encodeCookie encode the value to base64url(expires|payload|mac),
+// payload is the base64 of the value or the encrypted value,
+// mac is the HMAC of name|expires|payload
+func encodeCookie(keys *cookieKeys, name, value string, expires time.Time) (string, error) {
+	if len(keys.hash) == 0 {
+		return "", ErrCookieNoKey
+	}
+
+	plain := []byte(value)
+	if len(keys.block) > 0 {
+		aead := keys.block[0]
+		nonce := make([]byte, aead.NonceSize())
+		if _, err := rand.Read(nonce); err != nil {
+			return "", err
+		}
+		plain = aead.Seal(nonce, nonce, plain, []byte(name))
+	}
+
+	var exp int64
+	if !expires.IsZero() {
+		exp = expires.Unix()
+	}
+	msg := strconv.FormatInt(exp, 10) + "|" + base64.RawURLEncoding.EncodeToString(plain)
+	mac := cookieMAC(keys.hash[0], name, msg)
+	return base64.RawURLEncoding.EncodeToString([]byte(msg + "|" + mac)), nil
+}
+
+func decodeCookie(keys *cookieKeys, name, encoded string) (string, error) {
+	if len(keys.hash) == 0 {
+		return "", ErrCookieNoKey
+	}
+
+	b, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", ErrCookieInvalid
+	}
+	idx := strings.LastIndex(string(b), "|")
+	if idx < 0 {
+		return "", ErrCookieInvalid
+	}
+	msg, mac := string(b[:idx]), string(b[idx+1:])
+
+	valid := false
+	for _, key := range keys.hash {
+		if hmac.Equal([]byte(mac), []byte(cookieMAC(key, name, msg))) {
+			valid = true
+			break
+		}
+	}
+	if !valid {
+		return "", ErrCookieInvalid
+	}
+
+	parts := strings.SplitN(msg, "|", 2)
+	if len(parts) != 2 {
+		return "", ErrCookieInvalid
+	}
+	exp, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return "", ErrCookieInvalid
+	}
+	if exp > 0 && time.Now().Unix() > exp {
+		return "", ErrCookieExpired
+	}
+	plain, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return "", ErrCookieInvalid
+	}
+	if len(keys.block) == 0 {
+		return string(plain), nil
+	}
+
+	for _, aead := range keys.block {
+		if len(plain) < aead.NonceSize() {
+			break
+		}
+		nonce, ciphertext := plain[:aead.NonceSize()], plain[aead.NonceSize():]
+		if value, err := aead.Open(nil, nonce, ciphertext, []byte(name)); err == nil {
+			return string(value), nil
+		}
+	}
+	return "", ErrCookieInvalid
+}
+
+func cookieMAC(key []byte, name, msg string) string {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(name + "|" + msg))
+	return base64.RawURLEncoding.EncodeToString(h.Sum(nil))
+}
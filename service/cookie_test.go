@@ -0,0 +1,47 @@
+package service
+
+import (
+	"crypto/cipher"
+	"testing"
+	"time"
+)
+
+func TestSecureCookie(t *testing.T) {
+	oldKeys := &cookieKeys{hash: [][]byte{[]byte("old")}}
+	newKeys := &cookieKeys{hash: [][]byte{[]byte("new"), []byte("old")}}
+
+	encoded, err := encodeCookie(oldKeys, "uid", "10000", time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// rotated keys still decode the old cookies
+	if v, err := decodeCookie(newKeys, "uid", encoded); err != nil || v != "10000" {
+		t.Errorf("decode = %q %v", v, err)
+	}
+	// the value is bound to the cookie name
+	if _, err := decodeCookie(newKeys, "admin", encoded); err != ErrCookieInvalid {
+		t.Errorf("decode other name err = %v", err)
+	}
+	if _, err := decodeCookie(&cookieKeys{hash: [][]byte{[]byte("other")}}, "uid", encoded); err != ErrCookieInvalid {
+		t.Errorf("decode with unknown key err = %v", err)
+	}
+
+	expired, _ := encodeCookie(newKeys, "uid", "10000", time.Now().Add(-time.Second))
+	if _, err := decodeCookie(newKeys, "uid", expired); err != ErrCookieExpired {
+		t.Errorf("decode expired err = %v", err)
+	}
+
+	// encrypted
+	encKeys := &cookieKeys{hash: newKeys.hash, block: []cipher.AEAD{newAEAD("block")}}
+	encoded, _ = encodeCookie(encKeys, "uid", "secret value", time.Time{})
+	if v, err := decodeCookie(encKeys, "uid", encoded); err != nil || v != "secret value" {
+		t.Errorf("decode encrypted = %q %v", v, err)
+	}
+	if v, err := decodeCookie(newKeys, "uid", encoded); err == nil && v == "secret value" {
+		t.Errorf("value is not encrypted")
+	}
+
+	if _, err := encodeCookie(&cookieKeys{}, "uid", "1", time.Time{}); err != ErrCookieNoKey {
+		t.Errorf("encode without key err = %v", err)
+	}
+}
@@ -0,0 +1,291 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/QunQunLab/ego/common"
+	"github.com/QunQunLab/ego/conf"
+	"github.com/QunQunLab/ego/log"
+	"github.com/QunQunLab/ego/session"
+)
+
+const (
+	csrfSecretLen  = 32
+	csrfSessionKey = "_csrf"
+)
+
+var ErrNoCSRF = errors.New("csrf middleware is not used")
+
+type csrfContextKey struct{}
+
+// CSRFOptions csrf middleware options
+type CSRFOptions struct {
+	// cookie of the secret when the session middleware is not used
+	// (default: ego_csrf)
+	CookieName string
+	// request header of the token (default: X-CSRF-Token)
+	HeaderName string
+	// form field of the token (default: csrf_token)
+	FieldName string
+	// set the Secure attribute of the cookie, it's always set for https
+	Secure bool
+	// paths not checked, "/api/*" match the prefix,
+	// http_conf:csrf_exempt is appended
+	ExemptPaths []string
+	// origins allowed besides the request host, such as "https://a.com",
+	// http_conf:csrf_trusted_origins is appended
+	TrustedOrigins []string
+}
+
+// CSRF return a middleware protect the unsafe requests (POST, PUT, PATCH,
+// DELETE...) from cross site request forgery. The Origin or Referer must
+// be the request host or a trusted origin, and the request must carry the
+// token of Controller.CSRFToken by header or form field.
+//
+// The secret is kept in the session if the session middleware is used
+// before (synchronizer token), or in a cookie (double submit cookie).
+// Requests with "Authorization: Bearer" are exempted, as they don't rely
+// on the ambient credentials of browsers.
+//
+//	s.Use(manager.Middleware, service.CSRF())
+//
+//	[http_conf]
+//	csrf_exempt = /hook/*,/pay/notify
+//	csrf_trusted_origins = https://admin.example.com
+func CSRF(op ...CSRFOptions) Middleware {
+	opt := CSRFOptions{
+		CookieName: "ego_csrf",
+		HeaderName: "X-CSRF-Token",
+		FieldName:  "csrf_token",
+	}
+	if len(op) > 0 {
+		if op[0].CookieName != "" {
+			opt.CookieName = op[0].CookieName
+		}
+		if op[0].HeaderName != "" {
+			opt.HeaderName = op[0].HeaderName
+		}
+		if op[0].FieldName != "" {
+			opt.FieldName = op[0].FieldName
+		}
+		opt.Secure = op[0].Secure
+		opt.ExemptPaths = op[0].ExemptPaths
+		opt.TrustedOrigins = op[0].TrustedOrigins
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			secret := opt.secret(w, r)
+			r = r.WithContext(context.WithValue(r.Context(), csrfContextKey{}, secret))
+			if isSafeMethod(r.Method) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			cfg := requestConfig(r)
+			ropt := opt.withConfig(cfg)
+			if ropt.exempt(r) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if err := ropt.check(r, secret); err == ErrBodyTooLarge {
+				serveError(&Context{ResponseWriter: w, Request: r}, http.StatusRequestEntityTooLarge, default413Body)
+				return
+			} else if err != nil {
+				log.Warn("csrf check failed uri:%v reason:%v", r.URL.Path, err)
+				serveErrorf(w, requestBundle(r), &common.Forbidden, negotiateLang(r, supportedLangs(cfg)))
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// withConfig return the options with http_conf:csrf_exempt and
+// http_conf:csrf_trusted_origins of c appended
+func (opt CSRFOptions) withConfig(c *conf.Config) *CSRFOptions {
+	if section := c.Get("http_conf"); section != nil {
+		if paths, err := section.Strings("csrf_exempt"); err == nil {
+			opt.ExemptPaths = append(paths, opt.ExemptPaths...)
+		}
+		if origins, err := section.Strings("csrf_trusted_origins"); err == nil {
+			opt.TrustedOrigins = append(origins, opt.TrustedOrigins...)
+		}
+	}
+	return &opt
+}
+
+// secret return the csrf secret of the session or cookie,
+// a new one is created if not found
+func (opt *CSRFOptions) secret(w http.ResponseWriter, r *http.Request) []byte {
+	sess := session.FromContext(r.Context())
+	if sess != nil {
+		if secret, err := base64.RawURLEncoding.DecodeString(sess.GetString(csrfSessionKey)); err == nil && len(secret) == csrfSecretLen {
+			return secret
+		}
+	} else if cookie, err := r.Cookie(opt.CookieName); err == nil {
+		if secret, err := base64.RawURLEncoding.DecodeString(cookie.Value); err == nil && len(secret) == csrfSecretLen {
+			return secret
+		}
+	}
+
+	secret := make([]byte, csrfSecretLen)
+	if _, err := rand.Read(secret); err != nil {
+		panic(err)
+	}
+	encoded := base64.RawURLEncoding.EncodeToString(secret)
+	if sess != nil {
+		sess.Set(csrfSessionKey, encoded)
+	} else {
+		http.SetCookie(w, &http.Cookie{
+			Name:     opt.CookieName,
+			Value:    encoded,
+			Path:     "/",
+			HttpOnly: true,
+			Secure:   opt.Secure || r.TLS != nil,
+			SameSite: http.SameSiteLaxMode,
+		})
+	}
+	return secret
+}
+
+func (opt *CSRFOptions) exempt(r *http.Request) bool {
+	if auth := r.Header.Get("Authorization"); len(auth) > 7 && strings.EqualFold(auth[:7], "bearer ") {
+		return true
+	}
+	urlPath := strings.ToLower(r.URL.Path)
+	for _, p := range opt.ExemptPaths {
+		p = strings.ToLower(strings.TrimSpace(p))
+		if strings.HasSuffix(p, "*") {
+			if strings.HasPrefix(urlPath, p[:len(p)-1]) {
+				return true
+			}
+		} else if urlPath == p {
+			return true
+		}
+	}
+	return false
+}
+
+// check return the reason if the request is forged, or ErrBodyTooLarge
+func (opt *CSRFOptions) check(r *http.Request, secret []byte) error {
+	if origin := r.Header.Get("Origin"); origin != "" {
+		if !opt.trustedOrigin(r, origin) {
+			return fmt.Errorf("origin %v not allowed", origin)
+		}
+	} else if referer := r.Referer(); referer != "" {
+		if !opt.trustedOrigin(r, referer) {
+			return fmt.Errorf("referer %v not allowed", referer)
+		}
+	} else if r.TLS != nil {
+		// browsers always send referer for same origin https requests
+		// unless disabled by policy
+		return errors.New("no origin or referer")
+	}
+
+	token := r.Header.Get(opt.HeaderName)
+	if token == "" {
+		var err error
+		if token, err = formToken(r, opt.FieldName); err != nil {
+			return err
+		}
+	}
+	if token == "" {
+		return errors.New("no token")
+	}
+	if !validCSRFToken(token, secret) {
+		return errors.New("invalid token")
+	}
+	return nil
+}
+
+// formToken return the token of the form field. The body is limited by
+// HttpService.ServeHTTP before, a multipart form is parsed within
+// http_conf:multipart_memory and reused by the Get methods and SaveUploads.
+func formToken(r *http.Request, field string) (string, error) {
+	var err error
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch mediaType {
+	case "application/x-www-form-urlencoded":
+		err = r.ParseForm()
+	case "multipart/form-data":
+		err = r.ParseMultipartForm(multipartMemory(requestConfig(r)))
+	default:
+		return "", nil
+	}
+	if err = bodyError(err); err == ErrBodyTooLarge {
+		return "", err
+	}
+	return r.PostForm.Get(field), nil
+}
+
+func (opt *CSRFOptions) trustedOrigin(r *http.Request, origin string) bool {
+	u, err := url.Parse(origin)
+	if err != nil || u.Host == "" {
+		return false
+	}
+	if strings.EqualFold(u.Host, r.Host) {
+		return true
+	}
+	for _, o := range opt.TrustedOrigins {
+		t, err := url.Parse(strings.TrimSpace(o))
+		if err == nil && strings.EqualFold(t.Scheme, u.Scheme) && strings.EqualFold(t.Host, u.Host) {
+			return true
+		}
+	}
+	return false
+}
+
+// CSRFToken return the csrf token for the forms and ajax requests,
+// the token is masked by a random pad on every call against BREACH.
+//
+//	<input type="hidden" name="csrf_token" value="{{.csrf}}">
+func (c *Controller) CSRFToken() string {
+	secret, ok := c.Context().Value(csrfContextKey{}).([]byte)
+	if !ok {
+		panic(ErrNoCSRF)
+	}
+	return maskCSRFToken(secret)
+}
+
+// maskCSRFToken return base64(pad + (pad xor secret))
+func maskCSRFToken(secret []byte) string {
+	token := make([]byte, 2*len(secret))
+	pad := token[:len(secret)]
+	if _, err := rand.Read(pad); err != nil {
+		panic(err)
+	}
+	for i := range secret {
+		token[len(secret)+i] = pad[i] ^ secret[i]
+	}
+	return base64.RawURLEncoding.EncodeToString(token)
+}
+
+func validCSRFToken(token string, secret []byte) bool {
+	b, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil || len(b) != 2*len(secret) {
+		return false
+	}
+	pad, masked := b[:len(secret)], b[len(secret):]
+	for i := range masked {
+		masked[i] ^= pad[i]
+	}
+	return subtle.ConstantTimeCompare(masked, secret) == 1
+}
+
+func isSafeMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return true
+	}
+	return false
+}
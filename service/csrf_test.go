@@ -0,0 +1,136 @@
+package service
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestCSRF(t *testing.T) {
+	var token string
+	h := CSRF(CSRFOptions{ExemptPaths: []string{"/hook/*"}})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c := &Controller{Ctx: &Context{ResponseWriter: w, Request: r}}
+		token = c.CSRFToken()
+		io.WriteString(w, "ok")
+	}))
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "http://example.com/form", nil))
+	cookies := rec.Result().Cookies()
+	if rec.Code != http.StatusOK || len(cookies) != 1 || !cookies[0].HttpOnly {
+		t.Fatalf("get = %d cookies:%v", rec.Code, cookies)
+	}
+
+	post := func(path string, header map[string]string, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "http://example.com"+path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.AddCookie(cookies[0])
+		for k, v := range header {
+			req.Header.Set(k, v)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	cases := []struct {
+		path   string
+		header map[string]string
+		body   string
+		code   int
+	}{
+		{"/form", nil, "", http.StatusForbidden},
+		{"/form", map[string]string{"X-CSRF-Token": token}, "", http.StatusOK},
+		{"/form", nil, "csrf_token=" + token, http.StatusOK},
+		{"/form", map[string]string{"X-CSRF-Token": "bad"}, "", http.StatusForbidden},
+		{"/form", map[string]string{"X-CSRF-Token": token, "Origin": "http://evil.com"}, "", http.StatusForbidden},
+		{"/form", map[string]string{"X-CSRF-Token": token, "Referer": "http://example.com/form"}, "", http.StatusOK},
+		{"/form", map[string]string{"Authorization": "Bearer abc"}, "", http.StatusOK},
+		{"/hook/notify", nil, "", http.StatusOK},
+	}
+	for _, cs := range cases {
+		rec := post(cs.path, cs.header, cs.body)
+		if rec.Code != cs.code {
+			t.Errorf("%v %v %q = %d, want %d", cs.path, cs.header, cs.body, rec.Code, cs.code)
+		}
+		if rec.Code == http.StatusForbidden && !strings.Contains(rec.Body.String(), `"errcode":101`) {
+			t.Errorf("forbidden body = %s", rec.Body.String())
+		}
+	}
+}
+
+type CSRFUploadController struct {
+	Controller
+}
+
+func (c *CSRFUploadController) Token() {
+	io.WriteString(c.Ctx.ResponseWriter, c.CSRFToken())
+}
+
+func (c *CSRFUploadController) Save() {
+	files, err := c.SaveUploads(&DiskStorage{Dir: c.Ctx.Request.URL.Query().Get("dir")})
+	if err == ErrBodyTooLarge {
+		panic(err)
+	}
+	if err != nil || len(files) != 1 {
+		http.Error(c.Ctx.ResponseWriter, fmt.Sprintf("files:%v err:%v", files, err), http.StatusBadRequest)
+		return
+	}
+	io.WriteString(c.Ctx.ResponseWriter, c.GetString("title")+":"+files[0].Filename)
+}
+
+func TestCSRFUpload(t *testing.T) {
+	s := NewHttpService()
+	s.Register(&CSRFUploadController{})
+	s.Use(CSRF())
+	s.maxBodySize = 1 << 10
+	dir := t.TempDir()
+
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest("GET", "http://example.com/csrfupload/token", nil))
+	cookies := rec.Result().Cookies()
+	if rec.Code != http.StatusOK || len(cookies) != 1 {
+		t.Fatalf("token = %d cookies:%v", rec.Code, cookies)
+	}
+	token := rec.Body.String()
+
+	upload := func(header bool, size int) *httptest.ResponseRecorder {
+		var body bytes.Buffer
+		mw := multipart.NewWriter(&body)
+		if !header {
+			mw.WriteField("csrf_token", token)
+		}
+		mw.WriteField("title", "hello")
+		fw, _ := mw.CreateFormFile("file", fmt.Sprintf("a%v-%v.txt", header, size))
+		fw.Write(bytes.Repeat([]byte("x"), size))
+		mw.Close()
+
+		req := httptest.NewRequest("POST", "http://example.com/csrfupload/save?dir="+url.QueryEscape(dir), &body)
+		req.Header.Set("Content-Type", mw.FormDataContentType())
+		req.AddCookie(cookies[0])
+		if header {
+			req.Header.Set("X-CSRF-Token", token)
+		}
+		req.ContentLength = -1
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, req)
+		return rec
+	}
+
+	for _, header := range []bool{true, false} {
+		rec := upload(header, 10)
+		want := fmt.Sprintf("hello:a%v-10.txt", header)
+		if rec.Code != http.StatusOK || rec.Body.String() != want {
+			t.Errorf("header:%v = %d %q, want %q", header, rec.Code, rec.Body.String(), want)
+		}
+		if rec = upload(header, 2<<10); rec.Code != http.StatusRequestEntityTooLarge {
+			t.Errorf("header:%v large = %d %q", header, rec.Code, rec.Body.String())
+		}
+	}
+}
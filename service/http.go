@@ -1,6 +1,9 @@
 package service
 
 import (
+	"bufio"
+	"context"
+	"errors"
 	"fmt"
 	"net"
 	"net/http"
@@ -12,7 +15,9 @@ import (
 	"time"
 
 	"github.com/QunQunLab/ego/conf"
+	"github.com/QunQunLab/ego/i18n"
 	"github.com/QunQunLab/ego/log"
+	"github.com/QunQunLab/ego/view"
 )
 
 const (
@@ -22,20 +27,35 @@ const (
 var (
 	default404Body = []byte("404 page not found")
 	default405Body = []byte("405 method not allowed")
+	default413Body = []byte("413 request entity too large")
+	default503Body = []byte("503 service unavailable, request timeout")
 )
 
 var (
 	ErrorController = fmt.Errorf("controller is not ControllerInterface")
 )
 
+func init() {
+	// the sections read by the service, the routes of http_timeout and
+	// http_body_limit are the keys
+	conf.RegisterSection("http_conf", "rpc_conf", "cookie", "http_timeout", "http_body_limit")
+}
+
 // ControllerInfo holds information about the controller.
 type ControllerInfo struct {
 	controllerType reflect.Type
 	httpMethod     string
 	method         string
 	pattern        string
+	// negative means use the global setting
+	timeout     time.Duration
+	maxBodySize int64
 }
 
+// Middleware wraps the handler of the http service,
+// see HttpService.Use
+type Middleware func(http.Handler) http.Handler
+
 // HttpService default http service
 type HttpService struct {
 	pool sync.Pool
@@ -43,13 +63,145 @@ type HttpService struct {
 
 	//key:controller/method: val:controllerInfo
 	routMap map[string]interface{}
+
+	middlewares []Middleware
+	handler     http.Handler
+
+	// timeout of controller methods, 0 means no timeout
+	timeout time.Duration
+	// max request body size in bytes, 0 means no limit
+	maxBodySize int64
+
+	// nil means the global config, and the default view engine and i18n
+	// bundle
+	config *conf.Config
+	views  *view.Engine
+	bundle *i18n.Bundle
+}
+
+// ServiceOptions the options of NewHttpService and NewRpcService
+type ServiceOptions struct {
+	// the config of the service, the global config if nil, see conf.Load.
+	// The views and the messages of the http service are loaded from the
+	// view and i18n sections of it, see view.FromConfig and i18n.FromConfig
+	Config *conf.Config
+}
+
+type serviceContextKey struct{}
+
+// requestService return the http service serving r if its config is
+// injected, or nil
+func requestService(r *http.Request) *HttpService {
+	if r == nil {
+		return nil
+	}
+	s, _ := r.Context().Value(serviceContextKey{}).(*HttpService)
+	return s
+}
+
+// requestConfig return the config of the http service serving r
+func requestConfig(r *http.Request) *conf.Config {
+	if s := requestService(r); s != nil {
+		return s.config
+	}
+	return conf.Global()
+}
+
+// requestViews return the view engine of the http service serving r
+func requestViews(r *http.Request) *view.Engine {
+	if s := requestService(r); s != nil {
+		return s.views
+	}
+	return view.Default()
+}
+
+// requestBundle return the i18n bundle of the http service serving r, nil
+// means the default one
+func requestBundle(r *http.Request) *i18n.Bundle {
+	if s := requestService(r); s != nil {
+		return s.bundle
+	}
+	return nil
+}
+
+// Config return the config of the service
+func (s *HttpService) Config() *conf.Config {
+	if s.config != nil {
+		return s.config
+	}
+	return conf.Global()
 }
 
 func (s *HttpService) Name() string {
 	return "DefaultHttpService"
 }
 
+// Init load the timeouts and body size limits of controller methods.
+//
+// http_conf:timeout is the global timeout, and the http_timeout section
+// set the timeout of a route, 0 disable the timeout (streaming, websocket).
+// http_conf:max_body_size is the global request body limit, and the
+// http_body_limit section set the limit of a route, 0 means no limit.
+//
+//	[http_conf]
+//	timeout = 30s
+//	max_body_size = 1m
+//
+//	[http_timeout]
+//	/report/export = 5m
+//	/chat/join = 0
+//
+//	[http_body_limit]
+//	/user/avatar = 10m
 func (s *HttpService) Init() error {
+	if section := s.Config().Get("http_conf"); section != nil {
+		timeout, err := section.Duration("timeout")
+		if err == nil {
+			s.timeout = timeout
+		} else if _, ok := err.(*conf.NoKeyError); !ok {
+			return fmt.Errorf("http_conf:timeout err:%v", err)
+		}
+
+		size, err := section.MemSize("max_body_size")
+		if err == nil {
+			s.maxBodySize = int64(size)
+		} else if _, ok := err.(*conf.NoKeyError); !ok {
+			return fmt.Errorf("http_conf:max_body_size err:%v", err)
+		}
+	}
+
+	err := s.routeConf("http_timeout", func(route *ControllerInfo, section *conf.Section, key string) error {
+		timeout, err := section.Duration(key)
+		route.timeout = timeout
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	return s.routeConf("http_body_limit", func(route *ControllerInfo, section *conf.Section, key string) error {
+		size, err := section.MemSize(key)
+		route.maxBodySize = int64(size)
+		return err
+	})
+}
+
+// routeConf apply every key of the section to the route of the same pattern
+func (s *HttpService) routeConf(name string, apply func(route *ControllerInfo, section *conf.Section, key string) error) error {
+	section := s.Config().Get(name)
+	if section == nil {
+		return nil
+	}
+	for _, pattern := range section.Keys() {
+		route, ok := s.routMap[strings.ToLower(pattern)].(*ControllerInfo)
+		if !ok {
+			log.Warn("%v:%v route is not registered", name, pattern)
+			continue
+		}
+		if err := apply(route, section, pattern); err != nil {
+			return fmt.Errorf("%v:%v err:%v", name, pattern, err)
+		}
+	}
 	return nil
 }
 
@@ -65,12 +217,26 @@ func (s *HttpService) Register(c interface{}) {
 		route.method = rt.Method(i).Name
 		pattern := path.Join("/", strings.ToLower(controllerName), strings.ToLower(rt.Method(i).Name))
 		route.pattern = pattern
+		route.timeout = -1
+		route.maxBodySize = -1
 		s.routMap[pattern] = route
 	}
 }
 
+// Use append middlewares to the service, the first one is the outermost.
+// Use must be called before Start
+func (s *HttpService) Use(m ...Middleware) {
+	s.middlewares = append(s.middlewares, m...)
+
+	var h http.Handler = http.HandlerFunc(s.serveHTTP)
+	for i := len(s.middlewares) - 1; i >= 0; i-- {
+		h = s.middlewares[i](h)
+	}
+	s.handler = h
+}
+
 func (s *HttpService) Start() error {
-	section := conf.Get("http_conf")
+	section := s.Config().Get("http_conf")
 	port, err := section.Uint("port")
 	if err != nil {
 		log.Warn("HTTP_CONF:PORT config is undefined. Using port :8080 by default")
@@ -92,13 +258,36 @@ func (s *HttpService) RunMode() string {
 }
 
 // ServeHTTP conforms to the http.Handler interface.
+// The body limit of the route is applied before the middlewares, which
+// may read the body, such as the form token of CSRF.
 func (s *HttpService) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if s.config != nil {
+		req = req.WithContext(context.WithValue(req.Context(), serviceContextKey{}, s))
+	}
+	if c, ok := s.routMap[strings.ToLower(req.URL.Path)].(*ControllerInfo); ok {
+		maxBodySize := s.maxBodySize
+		if c.maxBodySize >= 0 {
+			maxBodySize = c.maxBodySize
+		}
+		if maxBodySize > 0 {
+			if req.ContentLength > maxBodySize {
+				serveError(&Context{ResponseWriter: w, Request: req}, http.StatusRequestEntityTooLarge, default413Body)
+				return
+			}
+			req.Body = http.MaxBytesReader(w, req.Body, maxBodySize)
+		}
+	}
+	s.handler.ServeHTTP(w, req)
+}
+
+func (s *HttpService) serveHTTP(w http.ResponseWriter, req *http.Request) {
 	c := s.pool.Get().(*Context)
 	defer s.pool.Put(c)
 
 	c.ResponseWriter = w
 	c.Request = req
 	c.S = time.Now()
+	c.Lang = negotiateLang(req, supportedLangs(s.Config()))
 
 	s.handleHTTPRequest(c)
 }
@@ -124,7 +313,7 @@ func (s *HttpService) handleHTTPRequest(ctx *Context) {
 	ctx.ResponseWriter.Header().Set("Server", EGOVersion)
 	if ref := ctx.Request.Referer(); ref != "" {
 		if u, err := url.Parse(ref); nil == err {
-			corsDomain := conf.GetKey("cors_domain")
+			corsDomain := s.Config().GetKey("cors_domain")
 			if corsDomain != "" {
 				if "*" == corsDomain || strings.Contains(","+corsDomain+",", ","+u.Host+",") {
 					ctx.ResponseWriter.Header().Set("Access-Control-Allow-Origin", u.Scheme+"://"+u.Host)
@@ -145,31 +334,101 @@ func (s *HttpService) handleHTTPRequest(ctx *Context) {
 	}
 
 	if c, ok := obj.(*ControllerInfo); ok {
-		var execController ControllerInterface
-		vc := reflect.New(c.controllerType)
-		execController, ok = vc.Interface().(ControllerInterface)
-		if !ok {
-			panic(ErrorController)
+		timeout := s.timeout
+		if c.timeout >= 0 {
+			timeout = c.timeout
+		}
+		if timeout > 0 {
+			s.callWithTimeout(ctx, c, timeout)
+			return
+		}
+		s.callController(ctx, c)
+	}
+}
+
+func (s *HttpService) callController(ctx *Context, c *ControllerInfo) {
+	var execController ControllerInterface
+	vc := reflect.New(c.controllerType)
+	execController, ok := vc.Interface().(ControllerInterface)
+	if !ok {
+		panic(ErrorController)
+	}
+
+	if f, ok := execController.(finisher); ok {
+		defer f.Finish()
+	}
+	defer func() {
+		if err := recover(); err != nil {
+			if err == ErrBodyTooLarge {
+				serveError(ctx, http.StatusRequestEntityTooLarge, default413Body)
+				return
+			}
+			execController.RenderError(err)
 		}
+	}()
+
+	ctx.ReqMethod = c.pattern
+
+	// 1.0 prepare
+	execController.Prepare(ctx)
+
+	// 1.1 before start execute logical
+	execController.BeforeProcess()
 
+	// 2.0 controller method
+	method := vc.MethodByName(c.method)
+	in := make([]reflect.Value, 0)
+	method.Call(in)
+}
+
+// callWithTimeout call the controller in a new goroutine with a request
+// context canceled after timeout, a 503 is replied if the controller has
+// not written the response header yet.
+// The controller gets a new Context, as the pooled one is reused as soon
+// as the request returns while the controller may be still running.
+func (s *HttpService) callWithTimeout(ctx *Context, c *ControllerInfo, timeout time.Duration) {
+	cancelCtx, cancel := context.WithTimeout(ctx.Request.Context(), timeout)
+	defer cancel()
+
+	tw := &timeoutWriter{ctx: cancelCtx, w: ctx.ResponseWriter, h: make(http.Header)}
+	for k, v := range ctx.ResponseWriter.Header() {
+		tw.h[k] = v
+	}
+	tc := &Context{
+		ResponseWriter: tw,
+		Request:        ctx.Request.WithContext(cancelCtx),
+		S:              ctx.S,
+		Lang:           ctx.Lang,
+	}
+
+	done := make(chan struct{})
+	panicChan := make(chan interface{}, 1)
+	go func() {
 		defer func() {
-			if err := recover(); err != nil {
-				execController.RenderError(err)
+			if p := recover(); p != nil {
+				panicChan <- p
 			}
 		}()
+		s.callController(tc, c)
+		close(done)
+	}()
 
-		ctx.ReqMethod = c.pattern
-
-		// 1.0 prepare
-		execController.Prepare(ctx)
-
-		// 1.1 before start execute logical
-		execController.BeforeProcess()
+	select {
+	case p := <-panicChan:
+		panic(p)
+	case <-done:
+		// the controller may return right after a write is rejected
+		if !tw.isTimedOut() {
+			return
+		}
+	case <-cancelCtx.Done():
+	}
 
-		// 2.0 controller method
-		method := vc.MethodByName(c.method)
-		in := make([]reflect.Value, 0)
-		method.Call(in)
+	if tw.timeout() {
+		log.Warn("the uri:%v timeout after %v", c.pattern, timeout)
+		serveError(ctx, http.StatusServiceUnavailable, default503Body)
+	} else {
+		log.Warn("the uri:%v timeout after %v, response already started", c.pattern, timeout)
 	}
 }
 
@@ -181,6 +440,7 @@ func (s *HttpService) Stop(w *sync.WaitGroup) {
 func serveError(ctx *Context, code int, defaultMessage []byte) {
 	var mimeJson = []string{"application/json"}
 	ctx.ResponseWriter.Header()["Content-Type"] = mimeJson
+	ctx.ResponseWriter.WriteHeader(code)
 	_, err := ctx.ResponseWriter.Write(defaultMessage)
 	if err != nil {
 		log.Error("cannot write message to writer during serve error: %v", err)
@@ -188,13 +448,113 @@ func serveError(ctx *Context, code int, defaultMessage []byte) {
 	return
 }
 
+// timeoutWriter guard the ResponseWriter of a controller running with
+// timeout, nothing can be written after timeout. The header is copied to
+// the ResponseWriter when written, so the timeout reply never races with
+// the controller.
+type timeoutWriter struct {
+	mu          sync.Mutex
+	ctx         context.Context
+	w           http.ResponseWriter
+	h           http.Header
+	wroteHeader bool
+	timedOut    bool
+}
+
+func (tw *timeoutWriter) Header() http.Header {
+	return tw.h
+}
+
+func (tw *timeoutWriter) WriteHeader(code int) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	tw.writeHeader(code)
+}
+
+func (tw *timeoutWriter) writeHeader(code int) {
+	if tw.checkTimeout() || tw.wroteHeader {
+		return
+	}
+	tw.wroteHeader = true
+	dst := tw.w.Header()
+	for k, v := range tw.h {
+		dst[k] = v
+	}
+	tw.w.WriteHeader(code)
+}
+
+func (tw *timeoutWriter) Write(p []byte) (int, error) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.checkTimeout() {
+		return 0, http.ErrHandlerTimeout
+	}
+	tw.writeHeader(http.StatusOK)
+	return tw.w.Write(p)
+}
+
+func (tw *timeoutWriter) Flush() {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.checkTimeout() {
+		return
+	}
+	tw.writeHeader(http.StatusOK)
+	if f, ok := tw.w.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (tw *timeoutWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.checkTimeout() {
+		return nil, nil, http.ErrHandlerTimeout
+	}
+	h, ok := tw.w.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("http.Hijacker is not implemented by the ResponseWriter")
+	}
+	tw.wroteHeader = true
+	return h.Hijack()
+}
+
+// checkTimeout reject writes once the context is done, must hold the lock
+func (tw *timeoutWriter) checkTimeout() bool {
+	if !tw.timedOut && tw.ctx.Err() != nil {
+		tw.timedOut = true
+	}
+	return tw.timedOut
+}
+
+func (tw *timeoutWriter) isTimedOut() bool {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	return tw.timedOut
+}
+
+// timeout mark the writer timed out,
+// return true if the timeout reply can be written
+func (tw *timeoutWriter) timeout() bool {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	tw.timedOut = true
+	return !tw.wroteHeader
+}
+
 // NewHttpService new default tcp service
-func NewHttpService() *HttpService {
+func NewHttpService(op ...ServiceOptions) *HttpService {
 	service := &HttpService{
 		routMap: map[string]interface{}{},
 	}
+	if len(op) > 0 && op[0].Config != nil {
+		service.config = op[0].Config
+		service.views = view.FromConfig(service.config)
+		service.bundle = i18n.FromConfig(service.config)
+	}
 	service.pool.New = func() interface{} {
 		return &Context{}
 	}
+	service.handler = http.HandlerFunc(service.serveHTTP)
 	return service
 }
@@ -0,0 +1,127 @@
+package service
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/QunQunLab/ego/conf"
+)
+
+type TimeoutController struct {
+	Controller
+}
+
+func (c *TimeoutController) Slow() {
+	<-c.Context().Done()
+	io.WriteString(c.Ctx.ResponseWriter, "too late")
+}
+
+func (c *TimeoutController) Fast() {
+	c.SetHeader("X-Fast", "1")
+	io.WriteString(c.Ctx.ResponseWriter, "ok")
+}
+
+func TestTimeout(t *testing.T) {
+	s := NewHttpService()
+	s.Register(&TimeoutController{})
+	s.timeout = 50 * time.Millisecond
+
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest("GET", "/timeout/slow", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("slow status = %d, want 503", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest("GET", "/timeout/fast", nil))
+	if rec.Code != http.StatusOK || rec.Body.String() != "ok" || rec.Header().Get("X-Fast") != "1" {
+		t.Errorf("fast = %d %q %v", rec.Code, rec.Body.String(), rec.Header())
+	}
+
+	// route timeout 0 disable the global timeout
+	s.routMap["/timeout/slow"].(*ControllerInfo).timeout = 0
+	rec = httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/timeout/slow", nil)
+	ctx, cancel := context.WithTimeout(req.Context(), 100*time.Millisecond)
+	defer cancel()
+	s.ServeHTTP(rec, req.WithContext(ctx))
+	if rec.Code != http.StatusOK || rec.Body.String() != "too late" {
+		t.Errorf("no timeout = %d %q", rec.Code, rec.Body.String())
+	}
+}
+
+type ConfigController struct {
+	Controller
+}
+
+func (c *ConfigController) Info() {
+	io.WriteString(c.Ctx.ResponseWriter, c.ClientIP()+" "+c.Lang())
+}
+
+func TestServiceConfig(t *testing.T) {
+	cases := []struct {
+		cors, timeout, slow string
+		proxies, langs      string
+		exempt              string
+
+		origin, info string
+		post         int
+	}{
+		{"a.com", "1s", "1m", "10.0.0.1", "en,cn", "/config/*", "http://a.com", "1.2.3.4 en", http.StatusOK},
+		{"b.com", "2s", "0", "127.0.0.1", "cn,en", "/none", "", "10.0.0.1 cn", http.StatusForbidden},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.cors, func(t *testing.T) {
+			t.Parallel()
+			s := NewHttpService(ServiceOptions{Config: conf.FromMap(map[string]map[string]string{
+				"": {"cors_domain": c.cors},
+				"http_conf": {
+					"timeout":         c.timeout,
+					"trusted_proxies": c.proxies,
+					"languages":       c.langs,
+					"csrf_exempt":     c.exempt,
+				},
+				"http_timeout": {"/timeout/slow": c.slow},
+			})})
+			s.Register(&TimeoutController{})
+			s.Register(&ConfigController{})
+			s.Use(CSRF())
+			if err := s.Init(); err != nil {
+				t.Fatal(err)
+			}
+			timeout, _ := time.ParseDuration(c.timeout)
+			slow, _ := time.ParseDuration(c.slow)
+			if s.timeout != timeout || s.routMap["/timeout/slow"].(*ControllerInfo).timeout != slow {
+				t.Errorf("timeout = %v, slow = %v", s.timeout, s.routMap["/timeout/slow"].(*ControllerInfo).timeout)
+			}
+
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest("GET", "/timeout/fast", nil)
+			req.Header.Set("Referer", "http://a.com/index")
+			s.ServeHTTP(rec, req)
+			if origin := rec.Header().Get("Access-Control-Allow-Origin"); origin != c.origin {
+				t.Errorf("origin = %q, want %q", origin, c.origin)
+			}
+
+			rec = httptest.NewRecorder()
+			req = httptest.NewRequest("GET", "/config/info", nil)
+			req.RemoteAddr = "10.0.0.1:1234"
+			req.Header.Set("X-Forwarded-For", "1.2.3.4")
+			s.ServeHTTP(rec, req)
+			if rec.Body.String() != c.info {
+				t.Errorf("info = %q, want %q", rec.Body.String(), c.info)
+			}
+
+			rec = httptest.NewRecorder()
+			s.ServeHTTP(rec, httptest.NewRequest("POST", "/config/info", nil))
+			if rec.Code != c.post {
+				t.Errorf("post = %d, want %d", rec.Code, c.post)
+			}
+		})
+	}
+}
@@ -0,0 +1,137 @@
+package service
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/QunQunLab/ego/conf"
+)
+
+// langParam is the query parameter and cookie to choose the language
+const langParam = "lang"
+
+// langAliases map the language tags of browsers to the ones used by the
+// error messages, such as zh-CN to cn
+var langAliases = map[string]string{
+	"zh": "cn",
+}
+
+// supportedLangs return http_conf:languages of c, the first one is the
+// default
+//
+//	[http_conf]
+//	languages = cn,en
+func supportedLangs(c *conf.Config) []string {
+	section := c.Get("http_conf")
+	if section == nil {
+		return nil
+	}
+	langs, _ := section.Strings("languages")
+	supported := make([]string, 0, len(langs))
+	for _, l := range langs {
+		if l = strings.ToLower(strings.TrimSpace(l)); l != "" {
+			supported = append(supported, l)
+		}
+	}
+	return supported
+}
+
+// negotiateLang return the language of the request, chosen from the lang
+// query parameter, the lang cookie and Accept-Language in order. It's the
+// default language if nothing matched, or "" if no language is supported.
+func negotiateLang(r *http.Request, supported []string) string {
+	if len(supported) == 0 {
+		return ""
+	}
+	if l := matchLang(r.URL.Query().Get(langParam), supported); l != "" {
+		return l
+	}
+	if cookie, err := r.Cookie(langParam); err == nil {
+		if l := matchLang(cookie.Value, supported); l != "" {
+			return l
+		}
+	}
+	for _, tag := range parseAcceptLanguage(r.Header.Get("Accept-Language")) {
+		if tag == "*" {
+			break
+		}
+		if l := matchLang(tag, supported); l != "" {
+			return l
+		}
+	}
+	return supported[0]
+}
+
+// matchLang match tag such as en-US to the supported en
+func matchLang(tag string, supported []string) string {
+	tag = strings.ToLower(strings.TrimSpace(strings.Replace(tag, "_", "-", -1)))
+	if tag == "" {
+		return ""
+	}
+	primary := tag
+	if i := strings.IndexByte(tag, '-'); i > 0 {
+		primary = tag[:i]
+	}
+	for _, candidate := range []string{tag, primary, langAliases[primary]} {
+		for _, l := range supported {
+			if candidate != "" && candidate == l {
+				return l
+			}
+		}
+	}
+	return ""
+}
+
+// parseAcceptLanguage return the tags of Accept-Language by quality,
+// the tags of q=0 are dropped
+func parseAcceptLanguage(header string) []string {
+	type weighted struct {
+		tag string
+		q   float64
+	}
+	var tags []weighted
+	for _, part := range strings.Split(header, ",") {
+		fields := strings.Split(part, ";")
+		tag := strings.TrimSpace(fields[0])
+		if tag == "" {
+			continue
+		}
+		q := 1.0
+		for _, f := range fields[1:] {
+			f = strings.TrimSpace(f)
+			if strings.HasPrefix(f, "q=") {
+				if v, err := strconv.ParseFloat(f[2:], 64); err == nil {
+					q = v
+				}
+			}
+		}
+		if q > 0 {
+			tags = append(tags, weighted{tag, q})
+		}
+	}
+	sort.SliceStable(tags, func(i, j int) bool {
+		return tags[i].q > tags[j].q
+	})
+
+	result := make([]string, len(tags))
+	for i, t := range tags {
+		result[i] = t.tag
+	}
+	return result
+}
+
+// Lang return the negotiated language of the request
+func (c *Controller) Lang() string {
+	return c.Ctx.Lang
+}
+
+// SetLang save the language to the lang cookie for the next requests,
+// the language of the current request is changed too
+func (c *Controller) SetLang(lang string, op ...CookieOption) {
+	if l := matchLang(lang, supportedLangs(c.Config())); l != "" {
+		c.Ctx.Lang = l
+		c.SetCookie(langParam, l, 365*24*3600, op...)
+	}
+}
@@ -0,0 +1,57 @@
+package service
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/QunQunLab/ego/common"
+)
+
+func TestNegotiateLang(t *testing.T) {
+	supported := []string{"cn", "en", "ja"}
+	cases := []struct {
+		url, cookie, accept, want string
+	}{
+		{"/", "", "", "cn"},
+		{"/", "", "en-US,en;q=0.9", "en"},
+		{"/", "", "fr;q=1,ja;q=0.5,en;q=0.8", "en"},
+		{"/", "", "zh-CN,zh;q=0.9", "cn"},
+		{"/", "", "en;q=0,ja", "ja"},
+		{"/", "", "fr,*;q=0.5", "cn"},
+		{"/", "ja", "en", "ja"},
+		{"/?lang=EN_us", "ja", "ja", "en"},
+		{"/?lang=fr", "", "ja", "ja"},
+	}
+	for _, c := range cases {
+		r := httptest.NewRequest("GET", c.url, nil)
+		if c.cookie != "" {
+			r.AddCookie(&http.Cookie{Name: langParam, Value: c.cookie})
+		}
+		if c.accept != "" {
+			r.Header.Set("Accept-Language", c.accept)
+		}
+		if got := negotiateLang(r, supported); got != c.want {
+			t.Errorf("%v cookie:%v accept:%v got %v, want %v", c.url, c.cookie, c.accept, got, c.want)
+		}
+	}
+
+	if got := negotiateLang(httptest.NewRequest("GET", "/?lang=en", nil), nil); got != "" {
+		t.Errorf("no supported languages got %v", got)
+	}
+}
+
+func TestRenderErrorLang(t *testing.T) {
+	rec := httptest.NewRecorder()
+	c := &Controller{Ctx: &Context{ResponseWriter: rec, Lang: "en"}}
+	c.RenderError(&common.Forbidden)
+
+	body := &errorBody{}
+	if err := json.Unmarshal(rec.Body.Bytes(), body); err != nil {
+		t.Fatal(err)
+	}
+	if rec.Code != http.StatusForbidden || body.ErrCode != common.ErrGeneralForbidden || body.ErrMsg != "You have no permission to access" {
+		t.Errorf("got %d %+v", rec.Code, body)
+	}
+}
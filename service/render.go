@@ -0,0 +1,53 @@
+package service
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/QunQunLab/ego/error"
+	"github.com/QunQunLab/ego/i18n"
+	"github.com/QunQunLab/ego/log"
+)
+
+// errorBody the standard error response with errcode and errmsg
+type errorBody struct {
+	ErrCode int                    `json:"errcode"`
+	ErrMsg  string                 `json:"errmsg"`
+	Data    map[string]interface{} `json:"data,omitempty"`
+}
+
+// serveErrorf write e as the json error response, the http status is
+// the registered status of the code, or 200 if not registered. The message
+// is looked up in b, or the default bundle if b is nil.
+func serveErrorf(w http.ResponseWriter, b *i18n.Bundle, e *error.Errorf, langs ...string) {
+	status := error.Status(e.Code, http.StatusOK)
+	body, err := json.Marshal(&errorBody{
+		ErrCode: e.GetCode(),
+		ErrMsg:  e.GetBundleMsg(b, langs...),
+		Data:    e.GetData(),
+	})
+	if err != nil {
+		log.Error("cannot marshal error response: %v", err)
+		status = http.StatusInternalServerError
+		body = []byte(http.StatusText(status))
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	if _, err = w.Write(body); err != nil {
+		log.Error("cannot write message to writer during serve error: %v", err)
+	}
+}
+
+// RenderError render the panic of error.Errorf as the json error
+// response in the language of the request, with the registered http status
+func (c *Controller) RenderError(err interface{}) {
+	switch e := err.(type) {
+	case *error.Errorf:
+		if e.Cause != nil {
+			log.Error("uri:%v err:%+v", c.Ctx.ReqMethod, e)
+		}
+		serveErrorf(c.Ctx.ResponseWriter, requestBundle(c.Ctx.Request), e, c.Ctx.Lang)
+	case error.Errorf:
+		serveErrorf(c.Ctx.ResponseWriter, requestBundle(c.Ctx.Request), &e, c.Ctx.Lang)
+	}
+}
@@ -12,6 +12,17 @@ import (
 type RpcService struct {
 	pool sync.Pool
 	ctx  *Context
+
+	// nil means the global config
+	config *conf.Config
+}
+
+// Config return the config of the service
+func (s *RpcService) Config() *conf.Config {
+	if s.config != nil {
+		return s.config
+	}
+	return conf.Global()
 }
 
 // Name the name of the service
@@ -30,7 +41,7 @@ func (s *RpcService) Register(h interface{}) {
 
 // Start start a service no blocking
 func (s *RpcService) Start() error {
-	section := conf.Get("rpc_conf")
+	section := s.Config().Get("rpc_conf")
 	port, err := section.Uint("port")
 	if err != nil {
 		log.Warn("RPC_CONF:PORT config is undefined. Using port :8081 by default")
@@ -52,8 +63,11 @@ func (s *RpcService) Stop(w *sync.WaitGroup) {
 }
 
 // NewRpcService new default rpc service
-func NewRpcService() *RpcService {
+func NewRpcService(op ...ServiceOptions) *RpcService {
 	service := &RpcService{}
+	if len(op) > 0 {
+		service.config = op[0].Config
+	}
 	service.pool.New = func() interface{} {
 		return &Context{}
 	}
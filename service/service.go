@@ -4,6 +4,7 @@ import (
 	"os"
 	"sync"
 
+	"github.com/QunQunLab/ego/conf"
 	"github.com/QunQunLab/ego/log"
 )
 
@@ -35,8 +36,21 @@ type Service interface {
 	RunMode() string
 }
 
-// Run start services
+// Run start services, or run the config command such as --check-config
+// and exit, see ConfigCommand. The command runs on the config of the first
+// service with a Config method, such as HttpService, or the global config.
 func Run(services []Service) {
+	cfg := conf.Global()
+	for _, s := range services {
+		if cs, ok := s.(interface{ Config() *conf.Config }); ok {
+			cfg = cs.Config()
+			break
+		}
+	}
+	if code, ok := ConfigCommandOf(cfg, os.Args[1:], os.Stdout, os.Stderr); ok {
+		os.Exit(code)
+	}
+
 	for _, s := range services {
 		err := s.Init()
 		if err != nil {
@@ -0,0 +1,184 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const defaultHeartbeat = 15 * time.Second
+
+var (
+	ErrStreamNotSupported = errors.New("streaming is not supported by the ResponseWriter")
+	ErrStreamClosed       = errors.New("stream closed")
+)
+
+// Event a server-sent event
+type Event struct {
+	ID    string
+	Event string
+	// string and []byte are sent as is, others are json encoded
+	Data interface{}
+	// reconnection time of the client
+	Retry time.Duration
+}
+
+// SSE server-sent events writer, see Controller.SSE
+//
+//	func (c *JobController) Progress() {
+//		sse := c.SSE()
+//		for p := range progress {
+//			if err := sse.Send("progress", p); err != nil {
+//				return // client gone
+//			}
+//		}
+//	}
+type SSE struct {
+	mu      sync.Mutex
+	w       http.ResponseWriter
+	flusher http.Flusher
+	ctx     context.Context
+	closed  bool
+	stop    chan struct{}
+}
+
+func newSSE(ctx context.Context, w http.ResponseWriter, heartbeat time.Duration) (*SSE, error) {
+	f, ok := w.(http.Flusher)
+	if !ok {
+		return nil, ErrStreamNotSupported
+	}
+
+	h := w.Header()
+	h.Set("Content-Type", "text/event-stream")
+	h.Set("Cache-Control", "no-cache")
+	h.Set("X-Accel-Buffering", "no") // nginx
+	h.Del("Content-Length")
+	w.WriteHeader(http.StatusOK)
+	f.Flush()
+
+	s := &SSE{
+		w:       w,
+		flusher: f,
+		ctx:     ctx,
+		stop:    make(chan struct{}),
+	}
+	if heartbeat > 0 {
+		go s.heartbeat(heartbeat)
+	}
+	return s, nil
+}
+
+// heartbeat send comment lines to keep the connection alive through proxies
+func (s *SSE) heartbeat(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-s.ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.Comment("ping"); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// Send send an event with name and data, event can be empty
+func (s *SSE) Send(event string, data interface{}) error {
+	return s.SendEvent(&Event{Event: event, Data: data})
+}
+
+// SendEvent send an event
+func (s *SSE) SendEvent(e *Event) error {
+	var buf bytes.Buffer
+	if e.ID != "" {
+		writeField(&buf, "id", e.ID)
+	}
+	if e.Event != "" {
+		writeField(&buf, "event", e.Event)
+	}
+	if e.Retry > 0 {
+		fmt.Fprintf(&buf, "retry: %d\n", e.Retry/time.Millisecond)
+	}
+
+	var data string
+	switch d := e.Data.(type) {
+	case nil:
+	case string:
+		data = d
+	case []byte:
+		data = string(d)
+	default:
+		b, err := json.Marshal(d)
+		if err != nil {
+			return err
+		}
+		data = string(b)
+	}
+	for _, line := range strings.Split(strings.Replace(data, "\r\n", "\n", -1), "\n") {
+		writeField(&buf, "data", line)
+	}
+	buf.WriteByte('\n')
+	return s.write(buf.Bytes())
+}
+
+// Comment send a comment line, which is ignored by the client
+func (s *SSE) Comment(comment string) error {
+	var buf bytes.Buffer
+	for _, line := range strings.Split(comment, "\n") {
+		buf.WriteString(": ")
+		buf.WriteString(line)
+		buf.WriteByte('\n')
+	}
+	buf.WriteByte('\n')
+	return s.write(buf.Bytes())
+}
+
+// Done return a channel closed when the client disconnected
+func (s *SSE) Done() <-chan struct{} {
+	return s.ctx.Done()
+}
+
+// Close stop the heartbeat, no more event can be sent after closed.
+// It's called automatically when the controller method returns
+func (s *SSE) Close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.closed {
+		s.closed = true
+		close(s.stop)
+	}
+}
+
+func (s *SSE) write(p []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return ErrStreamClosed
+	}
+	if err := s.ctx.Err(); err != nil {
+		return err
+	}
+	if _, err := s.w.Write(p); err != nil {
+		return err
+	}
+	s.flusher.Flush()
+	return nil
+}
+
+func writeField(buf *bytes.Buffer, name, value string) {
+	value = strings.NewReplacer("\r", "", "\n", "").Replace(value)
+	buf.WriteString(name)
+	buf.WriteString(": ")
+	buf.WriteString(value)
+	buf.WriteByte('\n')
+}
@@ -0,0 +1,117 @@
+package service
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestSSE(t *testing.T) {
+	rec := httptest.NewRecorder()
+	ctx, cancel := context.WithCancel(context.Background())
+	sse, err := newSSE(ctx, rec, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sse.Send("progress", map[string]int{"done": 1})
+	sse.SendEvent(&Event{ID: "2", Data: "line1\nline2", Retry: time.Second})
+	sse.Comment("ping")
+
+	want := "event: progress\ndata: {\"done\":1}\n\n" +
+		"id: 2\nretry: 1000\ndata: line1\ndata: line2\n\n" +
+		": ping\n\n"
+	if rec.Body.String() != want {
+		t.Errorf("body = %q, want %q", rec.Body.String(), want)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("Content-Type = %q", ct)
+	}
+
+	// client gone
+	cancel()
+	if err := sse.Send("", "x"); err != context.Canceled {
+		t.Errorf("Send after disconnect err = %v", err)
+	}
+
+	sse.Close()
+	if err := sse.Comment("x"); err != ErrStreamClosed {
+		t.Errorf("Send after close err = %v", err)
+	}
+}
+
+var (
+	streamNext = make(chan bool)
+	streamDone = make(chan bool, 1)
+)
+
+type StreamController struct {
+	Controller
+}
+
+func (c *StreamController) Chunks() {
+	i := 0
+	streamDone <- c.Stream(func(w io.Writer) bool {
+		// the next chunk is written after the last one is read
+		if i > 0 && !<-streamNext {
+			return false
+		}
+		i++
+		fmt.Fprintf(w, "chunk%d\n", i)
+		return true
+	})
+}
+
+func TestStream(t *testing.T) {
+	s := NewHttpService()
+	s.Register(&StreamController{})
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL + "/stream/chunks")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := bufio.NewReader(resp.Body)
+	for i := 1; i <= 3; i++ {
+		line, err := r.ReadString('\n')
+		if err != nil || line != fmt.Sprintf("chunk%d\n", i) {
+			t.Fatalf("chunk %d = %q err:%v", i, line, err)
+		}
+		streamNext <- i < 3
+	}
+	if rest, _ := io.ReadAll(r); len(rest) != 0 {
+		t.Errorf("rest = %q", rest)
+	}
+	resp.Body.Close()
+	if disconnected := <-streamDone; disconnected {
+		t.Error("stream ended is reported as disconnected")
+	}
+
+	// the client disconnected in the middle of the stream
+	resp, err = http.Get(ts.URL + "/stream/chunks")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if line, _ := bufio.NewReader(resp.Body).ReadString('\n'); line != "chunk1\n" {
+		t.Fatalf("chunk = %q", line)
+	}
+	resp.Body.Close()
+	for {
+		select {
+		case disconnected := <-streamDone:
+			if !disconnected {
+				t.Error("disconnect is not reported")
+			}
+			return
+		case streamNext <- true:
+		case <-time.After(5 * time.Second):
+			t.Fatal("stream is not stopped")
+		}
+	}
+}
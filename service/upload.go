@@ -0,0 +1,356 @@
+package service
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/QunQunLab/ego/conf"
+)
+
+const defaultMultipartMemory = 32 << 20 // 32M
+
+var (
+	ErrBodyTooLarge      = errors.New("request body too large")
+	ErrFileTooLarge      = errors.New("upload file too large")
+	ErrFileExtension     = errors.New("upload file extension not allowed")
+	ErrFileContentType   = errors.New("upload file content type not allowed")
+	ErrFormAlreadyParsed = errors.New("request form already parsed")
+)
+
+// UploadOptions checks of the uploaded files
+type UploadOptions struct {
+	// max size of a file in bytes, 0 means no limit
+	MaxSize int64
+	// allowed file extensions such as ".jpg", case insensitive
+	Extensions []string
+	// allowed media types sniffed from the file content such as "image/png",
+	// "image/*" match all images
+	ContentTypes []string
+}
+
+// UploadedFile an uploaded file saved by a Storage
+type UploadedFile struct {
+	Field    string
+	Filename string
+	// sniffed from the file content, not the one sent by the client
+	ContentType string
+	Size        int64
+	// returned by Storage.Save
+	Path string
+}
+
+// Storage save the uploaded files, Save return the location of the file
+type Storage interface {
+	Save(name string, r io.Reader) (string, error)
+}
+
+// DiskStorage save the uploaded files into Dir, a random suffix is added
+// to the file name if the file already exists
+type DiskStorage struct {
+	Dir string
+}
+
+func (s *DiskStorage) Save(name string, r io.Reader) (string, error) {
+	if err := os.MkdirAll(s.Dir, 0755); err != nil {
+		return "", err
+	}
+
+	name = filepath.Base(filepath.Clean("/" + name))
+	if name == "/" || name == "." {
+		name = "upload"
+	}
+	f, err := os.OpenFile(filepath.Join(s.Dir, name), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if os.IsExist(err) {
+		ext := filepath.Ext(name)
+		f, err = os.CreateTemp(s.Dir, strings.TrimSuffix(name, ext)+"-*"+ext)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	dst := f.Name()
+	_, err = io.Copy(f, r)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(dst)
+		return "", err
+	}
+	return dst, nil
+}
+
+// SaveFile save the uploaded file of the form key to dst
+//
+//	file, err := c.SaveFile("avatar", "/data/avatar/1.png", service.UploadOptions{
+//		MaxSize:      2 << 20,
+//		Extensions:   []string{".png", ".jpg"},
+//		ContentTypes: []string{"image/*"},
+//	})
+func (c *Controller) SaveFile(key, dst string, op ...UploadOptions) (*UploadedFile, error) {
+	f, h, err := c.GetFile(key)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var opt UploadOptions
+	if len(op) > 0 {
+		opt = op[0]
+	}
+	if opt.MaxSize > 0 && h.Size > opt.MaxSize {
+		return nil, ErrFileTooLarge
+	}
+	file := &UploadedFile{Field: key, Filename: h.Filename}
+	r, err := opt.check(file, f)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return nil, err
+	}
+	out, err := os.Create(dst)
+	if err != nil {
+		return nil, err
+	}
+	file.Size, err = io.Copy(out, r)
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(dst)
+		return nil, err
+	}
+	file.Path = dst
+	return file, nil
+}
+
+// SaveUploads stream all the files of a multipart request to storage,
+// without buffering them in memory or temp files. The other fields are
+// available by the Get methods after. If the form is parsed by a
+// middleware before, such as the form token of CSRF, the parsed files are
+// saved instead.
+// It must be called before any Get method, which parse the form.
+func (c *Controller) SaveUploads(storage Storage, op ...UploadOptions) ([]*UploadedFile, error) {
+	if c.RWrap.parsed {
+		return nil, ErrFormAlreadyParsed
+	}
+	c.RWrap.parsed = true
+
+	var opt UploadOptions
+	if len(op) > 0 {
+		opt = op[0]
+	}
+
+	req := c.Ctx.Request
+	if req.MultipartForm != nil {
+		// parsed by a middleware such as CSRF
+		return c.saveParsedUploads(storage, opt)
+	}
+	form := req.URL.Query()
+	c.RWrap.Form = form
+
+	mr, err := req.MultipartReader()
+	if err != nil {
+		c.RWrap.err = err
+		return nil, err
+	}
+
+	var (
+		files     []*UploadedFile
+		maxMemory = multipartMemory(c.Config())
+	)
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return files, bodyError(err)
+		}
+
+		name := part.FormName()
+		if name == "" {
+			continue
+		}
+		if part.FileName() == "" {
+			b, err := io.ReadAll(io.LimitReader(part, maxMemory+1))
+			if err != nil {
+				return files, bodyError(err)
+			}
+			if int64(len(b)) > maxMemory {
+				return files, fmt.Errorf("form field %v too large", name)
+			}
+			maxMemory -= int64(len(b))
+			form.Add(name, string(b))
+			continue
+		}
+
+		file := &UploadedFile{Field: name, Filename: filepath.Base(part.FileName())}
+		r, err := opt.check(file, part)
+		if err != nil {
+			return files, bodyError(err)
+		}
+		counter := &countReader{r: r}
+		if file.Path, err = storage.Save(file.Filename, counter); err != nil {
+			return files, bodyError(err)
+		}
+		file.Size = counter.n
+		files = append(files, file)
+	}
+	return files, nil
+}
+
+// saveParsedUploads save the files of the parsed multipart form
+func (c *Controller) saveParsedUploads(storage Storage, opt UploadOptions) ([]*UploadedFile, error) {
+	req := c.Ctx.Request
+	c.RWrap.Form = req.Form
+
+	var files []*UploadedFile
+	names := make([]string, 0, len(req.MultipartForm.File))
+	for name := range req.MultipartForm.File {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		for _, h := range req.MultipartForm.File[name] {
+			f, err := h.Open()
+			if err != nil {
+				return files, err
+			}
+			file := &UploadedFile{Field: name, Filename: filepath.Base(h.Filename)}
+			r, err := opt.check(file, f)
+			if err == nil {
+				counter := &countReader{r: r}
+				if file.Path, err = storage.Save(file.Filename, counter); err == nil {
+					file.Size = counter.n
+				}
+			}
+			f.Close()
+			if err != nil {
+				return files, err
+			}
+			files = append(files, file)
+		}
+	}
+	return files, nil
+}
+
+// multipartMemory return http_conf:multipart_memory of c, the max memory
+// used to parse a multipart form, the files exceed it are stored in temp
+// files
+func multipartMemory(c *conf.Config) int64 {
+	if section := c.Get("http_conf"); section != nil {
+		size, _ := section.MemSize("multipart_memory", defaultMultipartMemory)
+		return int64(size)
+	}
+	return defaultMultipartMemory
+}
+
+// bodyError convert the error of http.MaxBytesReader to ErrBodyTooLarge
+func bodyError(err error) error {
+	var maxErr *http.MaxBytesError
+	if errors.As(err, &maxErr) {
+		return ErrBodyTooLarge
+	}
+	return err
+}
+
+// check the extension, sniff the content type and limit the size of the file
+func (op *UploadOptions) check(file *UploadedFile, r io.Reader) (io.Reader, error) {
+	if len(op.Extensions) > 0 {
+		ext := strings.ToLower(filepath.Ext(file.Filename))
+		allowed := false
+		for _, e := range op.Extensions {
+			if strings.ToLower(e) == ext {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return nil, ErrFileExtension
+		}
+	}
+
+	head := make([]byte, 512)
+	n, err := io.ReadFull(r, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return nil, err
+	}
+	head = head[:n]
+	file.ContentType = sniffContentType(head)
+
+	if len(op.ContentTypes) > 0 {
+		allowed := false
+		for _, t := range op.ContentTypes {
+			if matchMediaType(t, file.ContentType) {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return nil, ErrFileContentType
+		}
+	}
+
+	r = io.MultiReader(bytes.NewReader(head), r)
+	if op.MaxSize > 0 {
+		r = &sizeLimitReader{r: r, n: op.MaxSize}
+	}
+	return r, nil
+}
+
+// matchMediaType match t like "image/*" or "image/png" with media type
+func matchMediaType(t, mediaType string) bool {
+	t = strings.ToLower(t)
+	if strings.HasSuffix(t, "/*") {
+		return strings.HasPrefix(mediaType, t[:len(t)-1])
+	}
+	return t == mediaType
+}
+
+func sniffContentType(head []byte) string {
+	mediaType, _, err := mime.ParseMediaType(http.DetectContentType(head))
+	if err != nil {
+		return "application/octet-stream"
+	}
+	return mediaType
+}
+
+// sizeLimitReader return ErrFileTooLarge if more than n bytes read
+type sizeLimitReader struct {
+	r io.Reader
+	n int64
+}
+
+func (l *sizeLimitReader) Read(p []byte) (int, error) {
+	if int64(len(p)) > l.n+1 {
+		p = p[:l.n+1]
+	}
+	n, err := l.r.Read(p)
+	l.n -= int64(n)
+	if l.n < 0 {
+		return 0, ErrFileTooLarge
+	}
+	return n, err
+}
+
+type countReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,106 @@
+package service
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func newUploadController(t *testing.T, files map[string]string) *Controller {
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	mw.WriteField("title", "hello")
+	for name, content := range files {
+		fw, err := mw.CreateFormFile("file", name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fw.Write([]byte(content))
+	}
+	mw.Close()
+
+	req := httptest.NewRequest("POST", "/upload?id=1", &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	c := &Controller{}
+	c.Prepare(&Context{ResponseWriter: httptest.NewRecorder(), Request: req})
+	return c
+}
+
+func TestSaveUploads(t *testing.T) {
+	dir := t.TempDir()
+	c := newUploadController(t, map[string]string{"a.txt": "plain text"})
+
+	files, err := c.SaveUploads(&DiskStorage{Dir: dir}, UploadOptions{
+		Extensions:   []string{".TXT"},
+		ContentTypes: []string{"text/*"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 || files[0].ContentType != "text/plain" || files[0].Size != 10 {
+		t.Fatalf("files = %+v", files[0])
+	}
+	if b, _ := os.ReadFile(filepath.Join(dir, "a.txt")); string(b) != "plain text" {
+		t.Errorf("saved file = %q", b)
+	}
+	if c.GetString("title") != "hello" || c.GetInt("id") != 1 {
+		t.Errorf("form = %v", c.GetParams())
+	}
+	if _, err = c.SaveUploads(&DiskStorage{Dir: dir}); err != ErrFormAlreadyParsed {
+		t.Errorf("second SaveUploads err = %v", err)
+	}
+}
+
+func TestSaveFileChecks(t *testing.T) {
+	dir := t.TempDir()
+	cases := []struct {
+		name string
+		op   UploadOptions
+		err  error
+	}{
+		{"a.exe", UploadOptions{Extensions: []string{".png"}}, ErrFileExtension},
+		{"a.png", UploadOptions{ContentTypes: []string{"image/*"}}, ErrFileContentType},
+		{"a.png", UploadOptions{MaxSize: 4}, ErrFileTooLarge},
+		{"a.png", UploadOptions{MaxSize: 100}, nil},
+	}
+	for _, cs := range cases {
+		c := newUploadController(t, map[string]string{cs.name: "not a png"})
+		_, err := c.SaveFile("file", filepath.Join(dir, cs.name), cs.op)
+		if err != cs.err {
+			t.Errorf("%v %+v err = %v, want %v", cs.name, cs.op, err, cs.err)
+		}
+	}
+}
+
+type UploadController struct {
+	Controller
+}
+
+func (c *UploadController) Form() {
+	c.Ctx.ResponseWriter.Write([]byte(c.GetString("title")))
+}
+
+func TestBodyLimit(t *testing.T) {
+	s := NewHttpService()
+	s.Register(&UploadController{})
+	s.maxBodySize = 8
+
+	body := "title=" + strings.Repeat("x", 10)
+	for _, chunked := range []bool{false, true} {
+		req := httptest.NewRequest("POST", "/upload/form", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		if chunked {
+			req.ContentLength = -1
+		}
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, req)
+		if rec.Code != http.StatusRequestEntityTooLarge {
+			t.Errorf("chunked:%v status = %d, want 413", chunked, rec.Code)
+		}
+	}
+}
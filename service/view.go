@@ -0,0 +1,46 @@
+package service
+
+import (
+	"html/template"
+	"net/http"
+
+	"github.com/QunQunLab/ego/log"
+	"github.com/QunQunLab/ego/view"
+)
+
+// RenderHTML render the view name with data by the view engine of the
+// service, the default one if the config is not injected,
+// the csrf function returns Controller.CSRFToken if the csrf middleware
+// is used. A 500 response is sent if the render failed.
+//
+//	func (c *UserController) Profile() {
+//		c.RenderHTML("user/profile", map[string]interface{}{"User": user})
+//	}
+func (c *Controller) RenderHTML(name string, data interface{}, op ...view.RenderOptions) error {
+	var ro view.RenderOptions
+	if len(op) > 0 {
+		ro = op[0]
+	}
+	if ro.Lang == "" {
+		ro.Lang = c.Ctx.Lang
+	}
+	if _, ok := c.Context().Value(csrfContextKey{}).([]byte); ok {
+		funcs := template.FuncMap{"csrf": c.CSRFToken}
+		for k, v := range ro.Funcs {
+			funcs[k] = v
+		}
+		ro.Funcs = funcs
+	}
+
+	w := c.Ctx.ResponseWriter
+	if w.Header().Get("Content-Type") == "" {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	}
+	if err := requestViews(c.Ctx.Request).Render(w, name, data, ro); err != nil {
+		log.Error("render view:%v err:%v", name, err)
+		w.Header().Del("Content-Type")
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return err
+	}
+	return nil
+}
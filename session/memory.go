@@ -0,0 +1,67 @@
+package session
+
+import (
+	"sync"
+	"time"
+)
+
+const memoryGCInterval = time.Minute
+
+type memoryEntry struct {
+	data    []byte
+	expires time.Time
+}
+
+// MemoryStore save sessions in memory, for single instance services and tests
+type MemoryStore struct {
+	mu       sync.Mutex
+	sessions map[string]memoryEntry
+	lastGC   time.Time
+}
+
+// NewMemoryStore return a memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		sessions: make(map[string]memoryEntry),
+		lastGC:   time.Now(),
+	}
+}
+
+func (s *MemoryStore) Load(id string) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.sessions[id]
+	if !ok {
+		return nil, nil
+	}
+	if time.Now().After(e.expires) {
+		delete(s.sessions, id)
+		return nil, nil
+	}
+	return e.data, nil
+}
+
+func (s *MemoryStore) Save(id string, data []byte, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	s.sessions[id] = memoryEntry{data: data, expires: now.Add(ttl)}
+
+	// remove the expired sessions
+	if now.Sub(s.lastGC) > memoryGCInterval {
+		s.lastGC = now
+		for k, e := range s.sessions {
+			if now.After(e.expires) {
+				delete(s.sessions, k)
+			}
+		}
+	}
+	return nil
+}
+
+func (s *MemoryStore) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.sessions, id)
+	return nil
+}
@@ -0,0 +1,41 @@
+package session
+
+import (
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+const redisPrefix = "session:"
+
+// RedisStore save sessions in redis, expired by redis TTL
+type RedisStore struct {
+	redisCmd redis.Cmdable
+	prefix   string
+}
+
+// NewRedisStore return a redis store, the keys are prefixed by
+// "session:" or prefix if given
+func NewRedisStore(cmd redis.Cmdable, prefix ...string) *RedisStore {
+	p := redisPrefix
+	if len(prefix) > 0 {
+		p = prefix[0]
+	}
+	return &RedisStore{redisCmd: cmd, prefix: p}
+}
+
+func (s *RedisStore) Load(id string) ([]byte, error) {
+	data, err := s.redisCmd.Get(s.prefix + id).Bytes()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	return data, err
+}
+
+func (s *RedisStore) Save(id string, data []byte, ttl time.Duration) error {
+	return s.redisCmd.Set(s.prefix+id, data, ttl).Err()
+}
+
+func (s *RedisStore) Delete(id string) error {
+	return s.redisCmd.Del(s.prefix + id).Err()
+}
@@ -0,0 +1,416 @@
+// Package session provides server side sessions stored in memory, redis
+// or sql database.
+//
+// SQLStore takes the *sql.DB of an orm engine instead of the engine, so
+// the package doesn't import xorm and the mysql driver for the apps using
+// the memory or redis store.
+//
+//	manager := session.NewManager(session.NewRedisStore(client))
+//	s := service.NewHttpService()
+//	s.Use(manager.Middleware)
+//
+//	func (c *UserController) Login() {
+//		// ... check password
+//		sess := c.Session()
+//		sess.Regenerate()
+//		sess.Set("uid", uid)
+//	}
+package session
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/QunQunLab/ego/log"
+)
+
+var ErrNoSession = errors.New("session middleware is not used")
+
+// the accessed time is saved when IdleTimeout/touchDivisor is elapsed
+const touchDivisor = 10
+
+type contextKey struct{}
+
+// Store save the encoded sessions, Load return nil data without error
+// if the session is not found or expired
+type Store interface {
+	Load(id string) ([]byte, error)
+	Save(id string, data []byte, ttl time.Duration) error
+	Delete(id string) error
+}
+
+// Options session options
+type Options struct {
+	// cookie name of the session id (default: ego_session)
+	CookieName string
+	// the session expires if not accessed within IdleTimeout (default: 30m)
+	IdleTimeout time.Duration
+	// the session expires after AbsoluteTimeout since created (default: 24h)
+	AbsoluteTimeout time.Duration
+
+	// cookie attributes, the cookie is always HttpOnly
+	Path     string
+	Domain   string
+	Secure   bool
+	SameSite http.SameSite
+}
+
+// Manager load and save the sessions of requests
+type Manager struct {
+	store Store
+	opt   Options
+}
+
+// NewManager return a session manager
+func NewManager(store Store, op ...Options) *Manager {
+	opt := Options{
+		CookieName:      "ego_session",
+		IdleTimeout:     30 * time.Minute,
+		AbsoluteTimeout: 24 * time.Hour,
+		Path:            "/",
+		SameSite:        http.SameSiteLaxMode,
+	}
+	if len(op) > 0 {
+		if op[0].CookieName != "" {
+			opt.CookieName = op[0].CookieName
+		}
+		if op[0].IdleTimeout > 0 {
+			opt.IdleTimeout = op[0].IdleTimeout
+		}
+		if op[0].AbsoluteTimeout > 0 {
+			opt.AbsoluteTimeout = op[0].AbsoluteTimeout
+		}
+		if op[0].Path != "" {
+			opt.Path = op[0].Path
+		}
+		if op[0].SameSite != 0 {
+			opt.SameSite = op[0].SameSite
+		}
+		opt.Domain = op[0].Domain
+		opt.Secure = op[0].Secure
+	}
+	return &Manager{store: store, opt: opt}
+}
+
+// FromContext return the session of the request context, nil if the
+// session middleware is not used
+func FromContext(ctx context.Context) *Session {
+	s, _ := ctx.Value(contextKey{}).(*Session)
+	return s
+}
+
+// Middleware load the session before the request, and save it when the
+// response header is written or the request is done
+func (m *Manager) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s := m.load(r)
+		sw := &sessionWriter{ResponseWriter: w, m: m, s: s}
+		next.ServeHTTP(sw, r.WithContext(context.WithValue(r.Context(), contextKey{}, s)))
+		sw.commit()
+		if err := m.save(s); err != nil {
+			log.Error("session:%v save err:%v", s.ID(), err)
+		}
+	})
+}
+
+// load the session of the request, or a new session
+func (m *Manager) load(r *http.Request) *Session {
+	now := time.Now()
+	if cookie, err := r.Cookie(m.opt.CookieName); err == nil && cookie.Value != "" {
+		data, err := m.store.Load(cookie.Value)
+		if err != nil {
+			log.Error("session:%v load err:%v", cookie.Value, err)
+		}
+		if data != nil {
+			s := &Session{id: cookie.Value}
+			if err = s.decode(data); err != nil {
+				log.Error("session:%v decode err:%v", cookie.Value, err)
+			} else if now.Sub(s.accessed) < m.opt.IdleTimeout && now.Sub(s.created) < m.opt.AbsoluteTimeout {
+				// refresh the idle timeout only after a tenth of it is
+				// elapsed, so the session isn't written on every request
+				if now.Sub(s.accessed) >= m.opt.IdleTimeout/touchDivisor {
+					s.accessed = now
+					s.touched = true
+				}
+				return s
+			} else {
+				m.store.Delete(cookie.Value)
+			}
+		}
+	}
+
+	return &Session{
+		id:       newID(),
+		values:   map[string]interface{}{},
+		created:  now,
+		accessed: now,
+		isNew:    true,
+	}
+}
+
+// save the session to store if changed or accessed
+func (m *Manager) save(s *Session) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.oldID != "" {
+		if err := m.store.Delete(s.oldID); err != nil {
+			return err
+		}
+		s.oldID = ""
+	}
+	if s.destroyed {
+		if s.isNew {
+			return nil
+		}
+		s.isNew = true
+		return m.store.Delete(s.id)
+	}
+	if !s.dirty && !s.touched {
+		return nil
+	}
+
+	ttl := m.opt.IdleTimeout
+	if left := m.opt.AbsoluteTimeout - time.Since(s.created); left < ttl {
+		ttl = left
+	}
+	if ttl <= 0 {
+		return m.store.Delete(s.id)
+	}
+	// the ttl is refreshed by any save
+	s.accessed = time.Now()
+	data, err := s.encode()
+	if err != nil {
+		return err
+	}
+	if err = m.store.Save(s.id, data, ttl); err != nil {
+		return err
+	}
+	s.dirty, s.touched, s.isNew = false, false, false
+	return nil
+}
+
+// cookie return the cookie to set, nil if the cookie is not changed
+func (m *Manager) cookie(s *Session) *http.Cookie {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cookie := &http.Cookie{
+		Name:     m.opt.CookieName,
+		Value:    s.id,
+		Path:     m.opt.Path,
+		Domain:   m.opt.Domain,
+		Secure:   m.opt.Secure,
+		HttpOnly: true,
+		SameSite: m.opt.SameSite,
+	}
+	switch {
+	case s.destroyed:
+		if s.isNew && s.oldID == "" {
+			return nil
+		}
+		cookie.Value = ""
+		cookie.MaxAge = -1
+	case s.isNew && !s.dirty:
+		// empty new session is not saved
+		return nil
+	case s.isNew, s.oldID != "":
+		// the cookie lives as long as the session
+		cookie.Expires = s.created.Add(m.opt.AbsoluteTimeout)
+	default:
+		return nil
+	}
+	return cookie
+}
+
+// sessionWriter set the session cookie and save the session before the
+// response header is written
+type sessionWriter struct {
+	http.ResponseWriter
+	m         *Manager
+	s         *Session
+	committed bool
+}
+
+func (w *sessionWriter) commit() {
+	if w.committed {
+		return
+	}
+	w.committed = true
+	if cookie := w.m.cookie(w.s); cookie != nil {
+		http.SetCookie(w.ResponseWriter, cookie)
+	}
+	// save before the client receives the response, so the next request
+	// of the client always sees the changes
+	if err := w.m.save(w.s); err != nil {
+		log.Error("session:%v save err:%v", w.s.ID(), err)
+	}
+}
+
+func (w *sessionWriter) WriteHeader(code int) {
+	w.commit()
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *sessionWriter) Write(p []byte) (int, error) {
+	w.commit()
+	return w.ResponseWriter.Write(p)
+}
+
+func (w *sessionWriter) Flush() {
+	w.commit()
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack implement http.Hijacker
+func (w *sessionWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	w.commit()
+	if h, ok := w.ResponseWriter.(http.Hijacker); ok {
+		return h.Hijack()
+	}
+	return nil, nil, errors.New("http.Hijacker is not implemented by the ResponseWriter")
+}
+
+// Unwrap is used by http.ResponseController
+func (w *sessionWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// Session a server side session, it's safe for concurrent use.
+// The values are json encoded in store, so numbers are float64 after
+// loaded, use GetString and GetInt for convenience.
+type Session struct {
+	mu       sync.Mutex
+	id       string
+	oldID    string
+	values   map[string]interface{}
+	created  time.Time
+	accessed time.Time
+
+	isNew     bool
+	dirty     bool
+	touched   bool
+	destroyed bool
+}
+
+// ID return the session id
+func (s *Session) ID() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.id
+}
+
+// Get return the value of key, nil if not found
+func (s *Session) Get(key string) interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.values[key]
+}
+
+// GetString return the string value of key
+func (s *Session) GetString(key string) string {
+	v, _ := s.Get(key).(string)
+	return v
+}
+
+// GetInt return the int value of key
+func (s *Session) GetInt(key string) int {
+	switch v := s.Get(key).(type) {
+	case int:
+		return v
+	case int64:
+		return int(v)
+	case float64:
+		return int(v)
+	}
+	return 0
+}
+
+// Set set the value of key
+func (s *Session) Set(key string, val interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.values[key] = val
+	s.dirty = true
+	s.destroyed = false
+}
+
+// Delete delete the key
+func (s *Session) Delete(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.values[key]; ok {
+		delete(s.values, key)
+		s.dirty = true
+	}
+}
+
+// Regenerate change the session id and keep the values and the created
+// time, so AbsoluteTimeout still applies. Call it after
+// login or privilege change to prevent session fixation.
+// It must be called before the response is written, as the new id is
+// sent by cookie
+func (s *Session) Regenerate() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.isNew && s.oldID == "" {
+		s.oldID = s.id
+	}
+	s.id = newID()
+	s.dirty = true
+}
+
+// Destroy remove all the values and delete the session, call it on logout
+func (s *Session) Destroy() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.values = map[string]interface{}{}
+	s.destroyed = true
+	s.dirty = false
+}
+
+type record struct {
+	Values   map[string]interface{} `json:"values"`
+	Created  int64                  `json:"created"`
+	Accessed int64                  `json:"accessed"`
+}
+
+func (s *Session) encode() ([]byte, error) {
+	return json.Marshal(&record{
+		Values:   s.values,
+		Created:  s.created.UnixNano(),
+		Accessed: s.accessed.UnixNano(),
+	})
+}
+
+func (s *Session) decode(data []byte) error {
+	r := &record{}
+	if err := json.Unmarshal(data, r); err != nil {
+		return err
+	}
+	s.values = r.Values
+	if s.values == nil {
+		s.values = map[string]interface{}{}
+	}
+	s.created = time.Unix(0, r.Created)
+	s.accessed = time.Unix(0, r.Accessed)
+	return nil
+}
+
+func newID() string {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
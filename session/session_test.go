@@ -0,0 +1,169 @@
+package session
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestSession(t *testing.T) {
+	store := NewMemoryStore()
+	m := NewManager(store, Options{IdleTimeout: 100 * time.Millisecond})
+	h := m.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s := FromContext(r.Context())
+		switch r.URL.Path {
+		case "/login":
+			s.Regenerate()
+			s.Set("uid", 10000)
+		case "/logout":
+			s.Destroy()
+			return
+		}
+		io.WriteString(w, s.GetString("name"))
+		s.Set("name", "ego") // saved after the response written
+	}))
+
+	do := func(path string, cookie *http.Cookie) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil)
+		if cookie != nil {
+			req.AddCookie(cookie)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+	cookieOf := func(rec *httptest.ResponseRecorder) *http.Cookie {
+		for _, c := range rec.Result().Cookies() {
+			if c.Name == "ego_session" {
+				return c
+			}
+		}
+		return nil
+	}
+
+	// login create the session
+	rec := do("/login", nil)
+	first := cookieOf(rec)
+	if first == nil || !first.HttpOnly {
+		t.Fatalf("login cookie = %v", first)
+	}
+
+	// the session is loaded, no cookie for the unchanged id
+	rec = do("/", first)
+	if rec.Body.String() != "ego" || cookieOf(rec) != nil {
+		t.Errorf("load = %q cookie:%v", rec.Body.String(), cookieOf(rec))
+	}
+
+	// login again regenerate the id and delete the old one
+	rec = do("/login", first)
+	second := cookieOf(rec)
+	if second == nil || second.Value == first.Value {
+		t.Fatalf("regenerated cookie = %v", second)
+	}
+	if data, _ := store.Load(first.Value); data != nil {
+		t.Errorf("old session is not deleted")
+	}
+
+	// idle timeout
+	time.Sleep(150 * time.Millisecond)
+	if rec = do("/", second); rec.Body.String() != "" {
+		t.Errorf("idle session = %q", rec.Body.String())
+	}
+
+	// logout
+	rec = do("/login", nil)
+	third := cookieOf(rec)
+	rec = do("/logout", third)
+	if c := cookieOf(rec); c == nil || c.MaxAge >= 0 {
+		t.Errorf("logout cookie = %v", c)
+	}
+	if data, _ := store.Load(third.Value); data != nil {
+		t.Errorf("destroyed session is not deleted")
+	}
+}
+
+type countStore struct {
+	Store
+	saves int
+}
+
+func (s *countStore) Save(id string, data []byte, ttl time.Duration) error {
+	s.saves++
+	return s.Store.Save(id, data, ttl)
+}
+
+func TestSessionTouch(t *testing.T) {
+	store := &countStore{Store: NewMemoryStore()}
+	m := NewManager(store, Options{IdleTimeout: time.Second})
+	h := m.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/login" {
+			FromContext(r.Context()).Set("uid", 10000)
+		}
+	}))
+	do := func(path string, cookie *http.Cookie) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil)
+		if cookie != nil {
+			req.AddCookie(cookie)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	cookies := do("/login", nil).Result().Cookies()
+	if len(cookies) == 0 || store.saves != 1 {
+		t.Fatalf("login cookies:%v saves:%v", cookies, store.saves)
+	}
+
+	// the session read right after saved is not written again
+	do("/", cookies[0])
+	if store.saves != 1 {
+		t.Errorf("saves = %v, want 1", store.saves)
+	}
+
+	// the idle timeout is refreshed after a tenth of it
+	time.Sleep(150 * time.Millisecond)
+	do("/", cookies[0])
+	if store.saves != 2 {
+		t.Errorf("saves = %v, want 2", store.saves)
+	}
+}
+
+func TestSessionRegenerate(t *testing.T) {
+	m := NewManager(NewMemoryStore(), Options{IdleTimeout: time.Second, AbsoluteTimeout: 200 * time.Millisecond})
+	h := m.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s := FromContext(r.Context())
+		switch r.URL.Path {
+		case "/login":
+			s.Set("name", "ego")
+		case "/regenerate":
+			s.Regenerate()
+		}
+		io.WriteString(w, s.GetString("name"))
+	}))
+	do := func(path string, cookie *http.Cookie) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil)
+		if cookie != nil {
+			req.AddCookie(cookie)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	first := do("/login", nil).Result().Cookies()
+	time.Sleep(120 * time.Millisecond)
+	rec := do("/regenerate", first[0])
+	second := rec.Result().Cookies()
+	if rec.Body.String() != "ego" || len(second) == 0 || second[0].Value == first[0].Value {
+		t.Fatalf("regenerate = %q cookies:%v", rec.Body.String(), second)
+	}
+
+	// the absolute timeout is counted from the login
+	time.Sleep(120 * time.Millisecond)
+	if rec = do("/", second[0]); rec.Body.String() != "" {
+		t.Errorf("session after absolute timeout = %q", rec.Body.String())
+	}
+}
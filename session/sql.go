@@ -0,0 +1,70 @@
+package session
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// SQLStore save sessions in a mysql table
+//
+//	CREATE TABLE `ego_session` (
+//	  `id` varchar(64) NOT NULL,
+//	  `data` blob NOT NULL,
+//	  `expires` bigint NOT NULL,
+//	  PRIMARY KEY (`id`),
+//	  KEY `idx_expires` (`expires`)
+//	) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+//
+// The orm engine can be used:
+//
+//	store := session.NewSQLStore(orm.Engine().DB().DB)
+type SQLStore struct {
+	db    *sql.DB
+	table string
+}
+
+// NewSQLStore return a sql store, the table is ego_session by default
+func NewSQLStore(db *sql.DB, table ...string) *SQLStore {
+	t := "ego_session"
+	if len(table) > 0 {
+		t = table[0]
+	}
+	return &SQLStore{db: db, table: t}
+}
+
+func (s *SQLStore) Load(id string) ([]byte, error) {
+	var (
+		data    []byte
+		expires int64
+	)
+	err := s.db.QueryRow(fmt.Sprintf("SELECT `data`, `expires` FROM `%s` WHERE `id` = ?", s.table), id).Scan(&data, &expires)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if time.Now().UnixNano() > expires {
+		return nil, nil
+	}
+	return data, nil
+}
+
+func (s *SQLStore) Save(id string, data []byte, ttl time.Duration) error {
+	_, err := s.db.Exec(fmt.Sprintf("INSERT INTO `%s` (`id`, `data`, `expires`) VALUES (?, ?, ?) "+
+		"ON DUPLICATE KEY UPDATE `data` = VALUES(`data`), `expires` = VALUES(`expires`)", s.table),
+		id, data, time.Now().Add(ttl).UnixNano())
+	return err
+}
+
+func (s *SQLStore) Delete(id string) error {
+	_, err := s.db.Exec(fmt.Sprintf("DELETE FROM `%s` WHERE `id` = ?", s.table), id)
+	return err
+}
+
+// GC delete the expired sessions, call it periodically
+func (s *SQLStore) GC() error {
+	_, err := s.db.Exec(fmt.Sprintf("DELETE FROM `%s` WHERE `expires` < ?", s.table), time.Now().UnixNano())
+	return err
+}
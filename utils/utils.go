@@ -4,14 +4,13 @@ import (
 	"github.com/QunQunLab/ego/error"
 )
 
+// Interceptor panic with a copy of err if guard is false, the stack of the
+// caller is captured, and the cause of err is kept
+//
+//	utils.Interceptor(dbErr == nil, error.Wrap(dbErr, ErrUserQuery), uid)
 func Interceptor(guard bool, err *error.Errorf, f ...interface{}) {
 	if !guard {
-		panic(
-			&error.Errorf{Code: err.Code,
-				Msg:  err.Msg,
-				Fmt:  f,
-				Data: nil},
-		)
+		panic(error.WithStack(err, 1, f...))
 	}
 }
 
@@ -1,6 +1,7 @@
 package utils
 
 import (
+	"io"
 	"testing"
 
 	"github.com/QunQunLab/ego/error"
@@ -25,6 +26,20 @@ func TestInterceptor(t *testing.T) {
 	Interceptor(false, ErrorPass)
 }
 
+func TestInterceptorCause(t *testing.T) {
+	var ErrorQuery = &error.Errorf{Code: 2, Msg: "query %v failed"}
+	defer func() {
+		e, ok := recover().(*error.Errorf)
+		if !ok {
+			t.Fatal("no Errorf panic")
+		}
+		if e.Cause != io.EOF || e.GetMsg() != "query user failed" || len(e.StackTrace()) == 0 {
+			t.Errorf("got %+v", e)
+		}
+	}()
+	Interceptor(false, error.Wrap(io.EOF, ErrorQuery), "user")
+}
+
 func TestInSlice(t *testing.T) {
 	var s []string
 	s = append(s, "a")
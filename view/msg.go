@@ -0,0 +1,22 @@
+package view
+
+import (
+	"fmt"
+
+	"github.com/QunQunLab/ego/error"
+	"github.com/QunQunLab/ego/i18n"
+)
+
+// msg return the message of e in the first of langs, the keys are looked
+// up in b, or the default bundle if b is nil
+func msg(b *i18n.Bundle, e interface{}, langs ...string) string {
+	switch v := e.(type) {
+	case *error.Errorf:
+		return v.GetBundleMsg(b, langs...)
+	case error.Errorf:
+		return v.GetBundleMsg(b, langs...)
+	case nil:
+		return ""
+	}
+	return fmt.Sprint(e)
+}
@@ -0,0 +1,325 @@
+// Package view renders html templates with layouts and partials.
+//
+//	views/
+//	  layouts/main.html     <html>{{block "title" .}}ego{{end}} {{template "content" .}}</html>
+//	  partials/header.html  <header>{{.User}}</header>
+//	  user/profile.html     {{define "title"}}profile{{end}}{{template "partials/header" .}}...
+//
+//	[view]
+//	dir = ./views
+//	ext = .html
+//	layout = layouts/main
+//	dev = true
+package view
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/QunQunLab/ego/conf"
+	"github.com/QunQunLab/ego/i18n"
+)
+
+// NoLayout is the RenderOptions.Layout to render without layout
+const NoLayout = "-"
+
+var (
+	funcsMu sync.RWMutex
+	funcs   = template.FuncMap{
+		// {{msg .Err}} return the localized message of an error.Errorf
+		"msg": func(e interface{}, langs ...string) string { return msg(nil, e, langs...) },
+		// {{lang}} return the language of the render
+		"lang": func() string { return "" },
+		// {{csrf}} return the csrf token, see service.Controller.CSRFToken
+		"csrf": func() string { return "" },
+		// {{t "cart.items" "count" .Count}} translate the key by i18n with
+		// the named arguments
+		"t": func(key string, args ...interface{}) string { return translate(nil, "", key, args) },
+	}
+)
+
+// AddFunc register a template function for all the engines, it must be
+// called before the templates are parsed, usually in init
+func AddFunc(name string, fn interface{}) {
+	funcsMu.Lock()
+	defer funcsMu.Unlock()
+	funcs[name] = fn
+}
+
+// translate translate key by b, or the default bundle if b is nil, args
+// are name and value pairs
+func translate(b *i18n.Bundle, lang, key string, args []interface{}) string {
+	var a i18n.Args
+	if len(args) > 0 {
+		a = i18n.Args{}
+		for i := 0; i+1 < len(args); i += 2 {
+			a[fmt.Sprint(args[i])] = args[i+1]
+		}
+	}
+	if b == nil {
+		return i18n.T(lang, key, a)
+	}
+	return b.T(lang, key, a)
+}
+
+// Options view engine options
+type Options struct {
+	// templates directory (default: ./views), ignored if FS is set
+	Dir string
+	// templates file system such as embed.FS
+	FS fs.FS
+	// template file extension (default: .html)
+	Ext string
+	// default layout, "" means no layout
+	Layout string
+	// directories of the shared templates parsed with every view,
+	// named by the path without extension (default: layouts, partials)
+	Shared []string
+	// parse the templates on every render, so changes are seen without restart
+	Dev bool
+	// functions of this engine only
+	Funcs template.FuncMap
+	// the bundle of the t and msg functions, the default bundle if nil
+	Bundle *i18n.Bundle
+}
+
+// RenderOptions options of a render
+type RenderOptions struct {
+	// override the default layout, NoLayout to render without layout
+	Layout string
+	// language of the msg function
+	Lang string
+	// functions of this render only, they must be registered by AddFunc
+	// or Options.Funcs before
+	Funcs template.FuncMap
+}
+
+// Engine parse and cache the templates
+type Engine struct {
+	opt   Options
+	fsys  fs.FS
+	mu    sync.RWMutex
+	cache map[string]*template.Template
+}
+
+// New return a view engine
+func New(op ...Options) *Engine {
+	opt := Options{
+		Dir:    "./views",
+		Ext:    ".html",
+		Shared: []string{"layouts", "partials"},
+	}
+	if len(op) > 0 {
+		if op[0].Dir != "" {
+			opt.Dir = op[0].Dir
+		}
+		if op[0].Ext != "" {
+			opt.Ext = op[0].Ext
+		}
+		if op[0].Shared != nil {
+			opt.Shared = op[0].Shared
+		}
+		opt.FS = op[0].FS
+		opt.Layout = op[0].Layout
+		opt.Dev = op[0].Dev
+		opt.Funcs = op[0].Funcs
+		opt.Bundle = op[0].Bundle
+	}
+
+	e := &Engine{
+		opt:   opt,
+		fsys:  opt.FS,
+		cache: make(map[string]*template.Template),
+	}
+	if e.fsys == nil {
+		e.fsys = os.DirFS(opt.Dir)
+	}
+	return e
+}
+
+// Render render the view name with data into w, w is untouched if failed
+func (e *Engine) Render(w io.Writer, name string, data interface{}, op ...RenderOptions) error {
+	var ro RenderOptions
+	if len(op) > 0 {
+		ro = op[0]
+	}
+	layout := e.opt.Layout
+	if ro.Layout == NoLayout {
+		layout = ""
+	} else if ro.Layout != "" {
+		layout = ro.Layout
+	}
+
+	t, err := e.lookup(layout, name)
+	if err != nil {
+		return err
+	}
+
+	bundle := e.opt.Bundle
+	bound := template.FuncMap{
+		"lang": func() string { return ro.Lang },
+		"t": func(key string, args ...interface{}) string {
+			return translate(bundle, ro.Lang, key, args)
+		},
+		"msg": func(e interface{}, langs ...string) string {
+			if len(langs) == 0 && ro.Lang != "" {
+				langs = []string{ro.Lang}
+			}
+			return msg(bundle, e, langs...)
+		},
+	}
+	for k, v := range ro.Funcs {
+		bound[k] = v
+	}
+	if t, err = t.Clone(); err != nil {
+		return err
+	}
+	t.Funcs(bound)
+
+	var buf bytes.Buffer
+	if err = t.Execute(&buf, data); err != nil {
+		return err
+	}
+	_, err = buf.WriteTo(w)
+	return err
+}
+
+// lookup return the cached template or parse it
+func (e *Engine) lookup(layout, name string) (*template.Template, error) {
+	key := layout + ":" + name
+	if !e.opt.Dev {
+		e.mu.RLock()
+		t, ok := e.cache[key]
+		e.mu.RUnlock()
+		if ok {
+			return t, nil
+		}
+	}
+
+	t, err := e.parse(layout, name)
+	if err != nil {
+		return nil, err
+	}
+	if !e.opt.Dev {
+		e.mu.Lock()
+		e.cache[key] = t
+		e.mu.Unlock()
+	}
+	return t, nil
+}
+
+// parse the shared templates and the view, the view is named "content"
+// if it has a layout, which includes it by {{template "content" .}}
+func (e *Engine) parse(layout, name string) (*template.Template, error) {
+	t := template.New("").Funcs(e.funcs())
+
+	for _, dir := range e.opt.Shared {
+		err := fs.WalkDir(e.fsys, dir, func(p string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() || !strings.HasSuffix(p, e.opt.Ext) {
+				return err
+			}
+			return e.parseFile(t, strings.TrimSuffix(p, e.opt.Ext), p)
+		})
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+	}
+
+	if layout != "" && t.Lookup(layout) == nil {
+		return nil, fmt.Errorf("view: layout %v not found", layout)
+	}
+	if layout == "" {
+		if err := e.parseFile(t, name, path.Clean(name)+e.opt.Ext); err != nil {
+			return nil, err
+		}
+		return t.Lookup(name), nil
+	}
+	if err := e.parseFile(t, "content", path.Clean(name)+e.opt.Ext); err != nil {
+		return nil, err
+	}
+	return t.Lookup(layout), nil
+}
+
+func (e *Engine) parseFile(t *template.Template, name, file string) error {
+	b, err := fs.ReadFile(e.fsys, file)
+	if err != nil {
+		return fmt.Errorf("view: %v", err)
+	}
+	if _, err = t.New(name).Parse(string(b)); err != nil {
+		return fmt.Errorf("view: %v", err)
+	}
+	return nil
+}
+
+func (e *Engine) funcs() template.FuncMap {
+	funcsMu.RLock()
+	defer funcsMu.RUnlock()
+	m := template.FuncMap{}
+	for k, v := range funcs {
+		m[k] = v
+	}
+	for k, v := range e.opt.Funcs {
+		m[k] = v
+	}
+	return m
+}
+
+var (
+	defaultMu     sync.Mutex
+	defaultEngine *Engine
+)
+
+// Default return the engine configured by the view section of the global
+// config, or the one set by SetDefault
+func Default() *Engine {
+	defaultMu.Lock()
+	defer defaultMu.Unlock()
+	if defaultEngine == nil {
+		defaultEngine = New(configOptions(conf.Global()))
+	}
+	return defaultEngine
+}
+
+func init() {
+	conf.RegisterSection("view")
+}
+
+// FromConfig return a new engine configured by the view section of c, the
+// t and msg functions use the bundle of the i18n section of c, see
+// i18n.FromConfig
+func FromConfig(c *conf.Config) *Engine {
+	opt := configOptions(c)
+	opt.Bundle = i18n.FromConfig(c)
+	return New(opt)
+}
+
+func configOptions(c *conf.Config) Options {
+	var opt Options
+	if section := c.Get("view"); section != nil {
+		opt.Dir, _ = section.String("dir")
+		opt.Ext, _ = section.String("ext")
+		opt.Layout, _ = section.String("layout")
+		opt.Dev, _ = section.Bool("dev")
+	}
+	return opt
+}
+
+// SetDefault set the default engine, such as an engine of embed.FS
+//
+//	//go:embed views
+//	var views embed.FS
+//
+//	sub, _ := fs.Sub(views, "views")
+//	view.SetDefault(view.New(view.Options{FS: sub, Layout: "layouts/main"}))
+func SetDefault(e *Engine) {
+	defaultMu.Lock()
+	defer defaultMu.Unlock()
+	defaultEngine = e
+}
@@ -0,0 +1,126 @@
+package view
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/QunQunLab/ego/error"
+	"github.com/QunQunLab/ego/i18n"
+)
+
+func testFS() fstest.MapFS {
+	return fstest.MapFS{
+		"layouts/main.html":    {Data: []byte(`<title>{{block "title" .}}ego{{end}}</title><body>{{template "content" .}}</body>`)},
+		"partials/header.html": {Data: []byte(`<h1>{{.Name}}</h1>`)},
+		"user/profile.html":    {Data: []byte(`{{define "title"}}profile{{end}}{{template "partials/header" .}}<p>{{upper .Name}}</p>`)},
+		"index.html":           {Data: []byte(`<p>{{.Name}}</p>`)},
+		"error.html":           {Data: []byte(`{{msg .Err}}|{{lang}}|{{csrf}}`)},
+	}
+}
+
+func TestRender(t *testing.T) {
+	AddFunc("upper", strings.ToUpper)
+	e := New(Options{FS: testFS(), Layout: "layouts/main"})
+
+	var buf bytes.Buffer
+	if err := e.Render(&buf, "user/profile", map[string]string{"Name": "<ego>"}); err != nil {
+		t.Fatal(err)
+	}
+	want := `<title>profile</title><body><h1>&lt;ego&gt;</h1><p>&lt;EGO&gt;</p></body>`
+	if buf.String() != want {
+		t.Fatalf("got %v, want %v", buf.String(), want)
+	}
+
+	buf.Reset()
+	if err := e.Render(&buf, "index", map[string]string{"Name": "a"}); err != nil {
+		t.Fatal(err)
+	}
+	if want := `<title>ego</title><body><p>a</p></body>`; buf.String() != want {
+		t.Fatalf("got %v, want %v", buf.String(), want)
+	}
+
+	buf.Reset()
+	if err := e.Render(&buf, "index", map[string]string{"Name": "a"}, RenderOptions{Layout: NoLayout}); err != nil {
+		t.Fatal(err)
+	}
+	if want := `<p>a</p>`; buf.String() != want {
+		t.Fatalf("got %v, want %v", buf.String(), want)
+	}
+
+	buf.Reset()
+	if err := e.Render(&buf, "missing", nil); err == nil || buf.Len() != 0 {
+		t.Fatalf("missing view rendered: %v %q", err, buf.String())
+	}
+}
+
+func TestRenderFuncs(t *testing.T) {
+	e := New(Options{FS: testFS()})
+	errf := &error.Errorf{Code: 10001, Msg: map[string]string{"cn": "未找到", "en": "not found"}}
+
+	var buf bytes.Buffer
+	err := e.Render(&buf, "error", map[string]interface{}{"Err": errf}, RenderOptions{
+		Lang:  "en",
+		Funcs: map[string]interface{}{"csrf": func() string { return "token" }},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "not found|en|token"; buf.String() != want {
+		t.Fatalf("got %v, want %v", buf.String(), want)
+	}
+
+	// the bound functions don't leak into the cached template
+	buf.Reset()
+	if err = e.Render(&buf, "error", map[string]interface{}{"Err": errf}); err != nil {
+		t.Fatal(err)
+	}
+	if want := "未找到||"; buf.String() != want {
+		t.Fatalf("got %v, want %v", buf.String(), want)
+	}
+}
+
+func TestRenderBundle(t *testing.T) {
+	b := i18n.NewBundle("en")
+	b.AddStrings("en", map[string]string{"hello": "hello {name}", "user.not_found": "no user"})
+	fsys := fstest.MapFS{"hello.html": {Data: []byte(`{{t "hello" "name" .Name}}|{{msg .Err}}`)}}
+	e := New(Options{FS: fsys, Bundle: b})
+
+	var buf bytes.Buffer
+	err := e.Render(&buf, "hello", map[string]interface{}{"Name": "ego", "Err": &error.Errorf{Key: "user.not_found"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "hello ego|no user"; buf.String() != want {
+		t.Fatalf("got %v, want %v", buf.String(), want)
+	}
+}
+
+func TestDev(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "index.html")
+	os.WriteFile(file, []byte("v1"), 0644)
+
+	cached := New(Options{Dir: dir})
+	dev := New(Options{Dir: dir, Dev: true})
+	for _, e := range []*Engine{cached, dev} {
+		if err := e.Render(&bytes.Buffer{}, "index", nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	os.WriteFile(file, []byte("v2"), 0644)
+
+	var buf bytes.Buffer
+	cached.Render(&buf, "index", nil)
+	if buf.String() != "v1" {
+		t.Fatalf("cached engine got %v", buf.String())
+	}
+	buf.Reset()
+	dev.Render(&buf, "index", nil)
+	if buf.String() != "v2" {
+		t.Fatalf("dev engine got %v", buf.String())
+	}
+}
@@ -0,0 +1,136 @@
+package websocket
+
+import (
+	"sync"
+	"time"
+)
+
+// Hub group connections into rooms and broadcast messages to them
+//
+//	var hub = websocket.NewHub()
+//
+//	func (c *ChatController) Join() {
+//		conn, err := c.WebSocket()
+//		if err != nil {
+//			return
+//		}
+//		room := c.GetString("room")
+//		hub.Join(room, conn)
+//		defer hub.LeaveAll(conn)
+//		for {
+//			_, msg, err := conn.ReadMessage()
+//			if err != nil {
+//				return
+//			}
+//			hub.Broadcast(room, websocket.TextMessage, msg)
+//		}
+//	}
+type Hub struct {
+	mu    sync.RWMutex
+	rooms map[string]map[*Conn]struct{}
+
+	// write timeout of every connection when broadcasting (default: 10s)
+	WriteTimeout time.Duration
+}
+
+// NewHub return a new empty hub
+func NewHub() *Hub {
+	return &Hub{
+		rooms:        make(map[string]map[*Conn]struct{}),
+		WriteTimeout: 10 * time.Second,
+	}
+}
+
+// Join add conn to room
+func (h *Hub) Join(room string, conn *Conn) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	conns, ok := h.rooms[room]
+	if !ok {
+		conns = make(map[*Conn]struct{})
+		h.rooms[room] = conns
+	}
+	conns[conn] = struct{}{}
+}
+
+// Leave remove conn from room, the room is removed when it's empty
+func (h *Hub) Leave(room string, conn *Conn) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.leave(room, conn)
+}
+
+// LeaveAll remove conn from all the rooms
+func (h *Hub) LeaveAll(conn *Conn) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for room := range h.rooms {
+		h.leave(room, conn)
+	}
+}
+
+func (h *Hub) leave(room string, conn *Conn) {
+	if conns, ok := h.rooms[room]; ok {
+		delete(conns, conn)
+		if len(conns) == 0 {
+			delete(h.rooms, room)
+		}
+	}
+}
+
+// Rooms return the names of all rooms
+func (h *Hub) Rooms() []string {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	rooms := make([]string, 0, len(h.rooms))
+	for room := range h.rooms {
+		rooms = append(rooms, room)
+	}
+	return rooms
+}
+
+// Count return the number of connections in room
+func (h *Hub) Count(room string) int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.rooms[room])
+}
+
+// Broadcast send a message to every connection in room concurrently,
+// connections failed to write are removed from the hub.
+// Return the number of connections the message sent to.
+func (h *Hub) Broadcast(room string, messageType int, data []byte) int {
+	h.mu.RLock()
+	conns := make([]*Conn, 0, len(h.rooms[room]))
+	for conn := range h.rooms[room] {
+		conns = append(conns, conn)
+	}
+	h.mu.RUnlock()
+
+	var (
+		wg     sync.WaitGroup
+		mu     sync.Mutex
+		sent   int
+		failed []*Conn
+	)
+	for _, conn := range conns {
+		wg.Add(1)
+		go func(conn *Conn) {
+			defer wg.Done()
+			err := conn.writeFrame(messageType, data, time.Now().Add(h.WriteTimeout))
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				failed = append(failed, conn)
+			} else {
+				sent++
+			}
+		}(conn)
+	}
+	wg.Wait()
+
+	for _, conn := range failed {
+		h.LeaveAll(conn)
+	}
+	return sent
+}
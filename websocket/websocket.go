@@ -0,0 +1,612 @@
+// Package websocket implements the WebSocket protocol defined in RFC 6455.
+package websocket
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// message types, the values are the frame opcodes
+const (
+	continuationFrame = 0
+	TextMessage       = 1
+	BinaryMessage     = 2
+	CloseMessage      = 8
+	PingMessage       = 9
+	PongMessage       = 10
+)
+
+// close codes defined in RFC 6455, section 11.7.
+const (
+	CloseNormalClosure    = 1000
+	CloseGoingAway        = 1001
+	CloseProtocolError    = 1002
+	CloseUnsupportedData  = 1003
+	CloseNoStatusReceived = 1005
+	CloseInvalidPayload   = 1007
+	ClosePolicyViolation  = 1008
+	CloseMessageTooBig    = 1009
+	CloseInternalError    = 1011
+)
+
+const (
+	guid = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+	maxControlPayload = 125
+	defaultReadLimit  = 1 << 20 // 1M
+	closeTimeout      = time.Second
+)
+
+var (
+	ErrReadLimit    = errors.New("websocket: message size exceeds the limit")
+	ErrClosed       = errors.New("websocket: use of closed connection")
+	ErrBadHandshake = errors.New("websocket: bad handshake")
+)
+
+// CloseError is returned by ReadMessage when the peer closed the connection
+type CloseError struct {
+	Code   int
+	Reason string
+}
+
+func (e *CloseError) Error() string {
+	return fmt.Sprintf("websocket: close %d %s", e.Code, e.Reason)
+}
+
+// Options upgrade options
+type Options struct {
+	// max size of a message read from peer in bytes (default: 1M)
+	ReadLimit int64
+	// CheckOrigin return true if the request Origin is acceptable,
+	// by default the Origin host must equal to the request Host
+	CheckOrigin func(r *http.Request) bool
+	// supported sub protocols in order of preference
+	Subprotocols []string
+}
+
+// Upgrade upgrade the HTTP connection to a WebSocket connection,
+// a http error is replied if the handshake fails.
+func Upgrade(w http.ResponseWriter, r *http.Request, op ...Options) (*Conn, error) {
+	var opt Options
+	if len(op) > 0 {
+		opt = op[0]
+	}
+
+	fail := func(status int, reason string) (*Conn, error) {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		http.Error(w, http.StatusText(status), status)
+		return nil, fmt.Errorf("%w: %s", ErrBadHandshake, reason)
+	}
+
+	if r.Method != http.MethodGet {
+		return fail(http.StatusMethodNotAllowed, "request method is not GET")
+	}
+	if !headerContains(r.Header, "Connection", "upgrade") {
+		return fail(http.StatusBadRequest, "'upgrade' token not found in 'Connection' header")
+	}
+	if !headerContains(r.Header, "Upgrade", "websocket") {
+		return fail(http.StatusBadRequest, "'websocket' token not found in 'Upgrade' header")
+	}
+	if r.Header.Get("Sec-Websocket-Version") != "13" {
+		return fail(http.StatusBadRequest, "unsupported version")
+	}
+	key := r.Header.Get("Sec-Websocket-Key")
+	if k, err := base64.StdEncoding.DecodeString(key); err != nil || len(k) != 16 {
+		return fail(http.StatusBadRequest, "invalid 'Sec-WebSocket-Key' header")
+	}
+	checkOrigin := opt.CheckOrigin
+	if checkOrigin == nil {
+		checkOrigin = sameOrigin
+	}
+	if !checkOrigin(r) {
+		return fail(http.StatusForbidden, "origin not allowed")
+	}
+
+	h, ok := w.(http.Hijacker)
+	if !ok {
+		return fail(http.StatusInternalServerError, "http.Hijacker is not implemented by the ResponseWriter")
+	}
+	netConn, brw, err := h.Hijack()
+	if err != nil {
+		return fail(http.StatusInternalServerError, err.Error())
+	}
+	var resp strings.Builder
+	resp.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n")
+	resp.WriteString("Sec-WebSocket-Accept: " + acceptKey(key) + "\r\n")
+	subprotocol := selectSubprotocol(r, opt.Subprotocols)
+	if subprotocol != "" {
+		resp.WriteString("Sec-WebSocket-Protocol: " + subprotocol + "\r\n")
+	}
+	resp.WriteString("\r\n")
+	if _, err = netConn.Write([]byte(resp.String())); err != nil {
+		netConn.Close()
+		return nil, err
+	}
+
+	c := newConn(netConn, brw.Reader, true, opt.ReadLimit)
+	c.subprotocol = subprotocol
+	return c, nil
+}
+
+// Dial open a client connection to a ws:// url, mostly used by tests
+// and service to service communication.
+func Dial(urlStr string, header http.Header, op ...Options) (*Conn, *http.Response, error) {
+	u, err := url.Parse(urlStr)
+	if err != nil {
+		return nil, nil, err
+	}
+	if u.Scheme != "ws" {
+		return nil, nil, fmt.Errorf("websocket: unsupported scheme %q", u.Scheme)
+	}
+	host := u.Host
+	if u.Port() == "" {
+		host = net.JoinHostPort(u.Hostname(), "80")
+	}
+	netConn, err := net.Dial("tcp", host)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	nonce := make([]byte, 16)
+	rand.Read(nonce)
+	key := base64.StdEncoding.EncodeToString(nonce)
+
+	req := &http.Request{
+		Method:     http.MethodGet,
+		URL:        u,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     http.Header{},
+		Host:       u.Host,
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Sec-WebSocket-Key", key)
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	if err = req.Write(netConn); err != nil {
+		netConn.Close()
+		return nil, nil, err
+	}
+
+	br := bufio.NewReader(netConn)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		netConn.Close()
+		return nil, nil, err
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols ||
+		resp.Header.Get("Sec-Websocket-Accept") != acceptKey(key) {
+		netConn.Close()
+		return nil, resp, ErrBadHandshake
+	}
+
+	var opt Options
+	if len(op) > 0 {
+		opt = op[0]
+	}
+	c := newConn(netConn, br, false, opt.ReadLimit)
+	c.subprotocol = resp.Header.Get("Sec-Websocket-Protocol")
+	return c, resp, nil
+}
+
+// Conn a WebSocket connection.
+// ReadMessage must not be called concurrently, the write methods are
+// safe for concurrent use.
+type Conn struct {
+	conn     net.Conn
+	br       *bufio.Reader
+	isServer bool
+
+	readLimit   int64
+	subprotocol string
+	pingHandler func(data []byte) error
+	pongHandler func(data []byte) error
+
+	wmu       sync.Mutex
+	closeSent bool
+	closed    bool
+}
+
+func newConn(conn net.Conn, br *bufio.Reader, isServer bool, readLimit int64) *Conn {
+	if readLimit <= 0 {
+		readLimit = defaultReadLimit
+	}
+	c := &Conn{
+		conn:      conn,
+		br:        br,
+		isServer:  isServer,
+		readLimit: readLimit,
+	}
+	c.pingHandler = func(data []byte) error {
+		return c.WriteControl(PongMessage, data, time.Now().Add(closeTimeout))
+	}
+	return c
+}
+
+// Subprotocol return the negotiated sub protocol
+func (c *Conn) Subprotocol() string {
+	return c.subprotocol
+}
+
+// RemoteAddr return the remote network address
+func (c *Conn) RemoteAddr() net.Addr {
+	return c.conn.RemoteAddr()
+}
+
+// SetReadLimit set the max size of a message read from peer
+func (c *Conn) SetReadLimit(limit int64) {
+	c.readLimit = limit
+}
+
+// SetReadDeadline set the read deadline of the underlying connection
+func (c *Conn) SetReadDeadline(t time.Time) error {
+	return c.conn.SetReadDeadline(t)
+}
+
+// SetWriteDeadline set the write deadline of the underlying connection
+func (c *Conn) SetWriteDeadline(t time.Time) error {
+	return c.conn.SetWriteDeadline(t)
+}
+
+// SetPingHandler set the handler of ping messages, by default a pong is replied
+func (c *Conn) SetPingHandler(h func(data []byte) error) {
+	c.pingHandler = h
+}
+
+// SetPongHandler set the handler of pong messages,
+// usually used to extend the read deadline
+func (c *Conn) SetPongHandler(h func(data []byte) error) {
+	c.pongHandler = h
+}
+
+// ReadMessage read a complete message, ping and pong are handled by the
+// handlers, a *CloseError is returned after the peer closed the connection
+func (c *Conn) ReadMessage() (messageType int, p []byte, err error) {
+	for {
+		fin, op, payload, err := c.readFrame(int64(len(p)))
+		if err != nil {
+			return 0, nil, err
+		}
+
+		switch op {
+		case PingMessage:
+			if c.pingHandler != nil {
+				if err = c.pingHandler(payload); err != nil {
+					return 0, nil, err
+				}
+			}
+			continue
+		case PongMessage:
+			if c.pongHandler != nil {
+				if err = c.pongHandler(payload); err != nil {
+					return 0, nil, err
+				}
+			}
+			continue
+		case CloseMessage:
+			return 0, nil, c.handleClose(payload)
+		case continuationFrame:
+			if messageType == 0 {
+				return 0, nil, c.fail(CloseProtocolError, "unexpected continuation frame")
+			}
+		case TextMessage, BinaryMessage:
+			if messageType != 0 {
+				return 0, nil, c.fail(CloseProtocolError, "expect continuation frame")
+			}
+			messageType = op
+		default:
+			return 0, nil, c.fail(CloseProtocolError, fmt.Sprintf("unknown opcode %d", op))
+		}
+
+		p = append(p, payload...)
+		if fin {
+			if messageType == TextMessage && !utf8.Valid(p) {
+				return 0, nil, c.fail(CloseInvalidPayload, "invalid utf8 payload")
+			}
+			return messageType, p, nil
+		}
+	}
+}
+
+// readFrame read a frame, read is the size of the message already read
+func (c *Conn) readFrame(read int64) (fin bool, op int, payload []byte, err error) {
+	var head [2]byte
+	if _, err = io.ReadFull(c.br, head[:]); err != nil {
+		return
+	}
+	fin = head[0]&0x80 != 0
+	op = int(head[0] & 0x0f)
+	masked := head[1]&0x80 != 0
+	length := int64(head[1] & 0x7f)
+
+	if head[0]&0x70 != 0 {
+		err = c.fail(CloseProtocolError, "unexpected reserved bits")
+		return
+	}
+	if masked != c.isServer {
+		err = c.fail(CloseProtocolError, "bad mask flag")
+		return
+	}
+
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err = io.ReadFull(c.br, ext[:]); err != nil {
+			return
+		}
+		length = int64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err = io.ReadFull(c.br, ext[:]); err != nil {
+			return
+		}
+		length = int64(binary.BigEndian.Uint64(ext[:]))
+		if length < 0 {
+			err = c.fail(CloseProtocolError, "invalid payload length")
+			return
+		}
+	}
+
+	if op >= CloseMessage {
+		if length > maxControlPayload || !fin {
+			err = c.fail(CloseProtocolError, "invalid control frame")
+			return
+		}
+	} else if length > c.readLimit-read {
+		c.fail(CloseMessageTooBig, "")
+		err = ErrReadLimit
+		return
+	}
+
+	var mask [4]byte
+	if masked {
+		if _, err = io.ReadFull(c.br, mask[:]); err != nil {
+			return
+		}
+	}
+
+	payload = make([]byte, length)
+	if _, err = io.ReadFull(c.br, payload); err != nil {
+		return
+	}
+	if masked {
+		maskBytes(mask, payload)
+	}
+	return
+}
+
+// handleClose echo the close frame if we didn't initiate the closing,
+// then close the connection
+func (c *Conn) handleClose(payload []byte) error {
+	closeErr := &CloseError{Code: CloseNoStatusReceived}
+	if len(payload) >= 2 {
+		closeErr.Code = int(binary.BigEndian.Uint16(payload))
+		closeErr.Reason = string(payload[2:])
+		if !validCloseCode(closeErr.Code) || !utf8.ValidString(closeErr.Reason) {
+			return c.fail(CloseProtocolError, "invalid close payload")
+		}
+	} else if len(payload) == 1 {
+		return c.fail(CloseProtocolError, "invalid close payload")
+	}
+
+	echo := FormatCloseMessage(closeErr.Code, "")
+	if closeErr.Code == CloseNoStatusReceived {
+		echo = nil
+	}
+	c.WriteControl(CloseMessage, echo, time.Now().Add(closeTimeout))
+	c.conn.Close()
+	return closeErr
+}
+
+// fail send a close frame with code and close the connection
+func (c *Conn) fail(code int, reason string) error {
+	c.WriteControl(CloseMessage, FormatCloseMessage(code, reason), time.Now().Add(closeTimeout))
+	c.conn.Close()
+	return &CloseError{Code: code, Reason: reason}
+}
+
+// WriteMessage write a text or binary message
+func (c *Conn) WriteMessage(messageType int, data []byte) error {
+	if messageType != TextMessage && messageType != BinaryMessage {
+		return c.WriteControl(messageType, data, time.Time{})
+	}
+	return c.writeFrame(messageType, data, time.Time{})
+}
+
+// WriteText write a text message
+func (c *Conn) WriteText(text string) error {
+	return c.WriteMessage(TextMessage, []byte(text))
+}
+
+// WriteControl write a close, ping or pong message with deadline
+func (c *Conn) WriteControl(messageType int, data []byte, deadline time.Time) error {
+	if messageType != CloseMessage && messageType != PingMessage && messageType != PongMessage {
+		return fmt.Errorf("websocket: invalid control message type %d", messageType)
+	}
+	if len(data) > maxControlPayload {
+		return errors.New("websocket: control frame payload too large")
+	}
+	return c.writeFrame(messageType, data, deadline)
+}
+
+// Ping send a ping message
+func (c *Conn) Ping(data []byte) error {
+	return c.WriteControl(PingMessage, data, time.Now().Add(closeTimeout))
+}
+
+func (c *Conn) writeFrame(op int, data []byte, deadline time.Time) error {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	if c.closeSent {
+		return ErrClosed
+	}
+
+	frame := make([]byte, 0, len(data)+14)
+	frame = append(frame, 0x80|byte(op))
+	var maskBit byte
+	if !c.isServer {
+		maskBit = 0x80
+	}
+	switch n := len(data); {
+	case n <= 125:
+		frame = append(frame, maskBit|byte(n))
+	case n <= 0xffff:
+		frame = append(frame, maskBit|126, byte(n>>8), byte(n))
+	default:
+		frame = append(frame, maskBit|127)
+		frame = binary.BigEndian.AppendUint64(frame, uint64(n))
+	}
+	if c.isServer {
+		frame = append(frame, data...)
+	} else {
+		var mask [4]byte
+		rand.Read(mask[:])
+		frame = append(frame, mask[:]...)
+		start := len(frame)
+		frame = append(frame, data...)
+		maskBytes(mask, frame[start:])
+	}
+
+	c.conn.SetWriteDeadline(deadline)
+	_, err := c.conn.Write(frame)
+	if op == CloseMessage {
+		c.closeSent = true
+	}
+	return err
+}
+
+// Close start the closing handshake with CloseNormalClosure,
+// wait for the peer's reply at most one second, then close the connection.
+// Close must not be called while another goroutine is in ReadMessage,
+// use WriteControl with a CloseMessage there instead.
+func (c *Conn) Close() error {
+	return c.CloseWithCode(CloseNormalClosure, "")
+}
+
+// CloseWithCode is Close with a close code and reason
+func (c *Conn) CloseWithCode(code int, reason string) error {
+	c.wmu.Lock()
+	if c.closed {
+		c.wmu.Unlock()
+		return nil
+	}
+	c.closed = true
+	c.wmu.Unlock()
+
+	deadline := time.Now().Add(closeTimeout)
+	if err := c.WriteControl(CloseMessage, FormatCloseMessage(code, reason), deadline); err == nil {
+		// wait for the close frame of the peer
+		c.conn.SetReadDeadline(deadline)
+		for {
+			if _, _, err = c.ReadMessage(); err != nil {
+				break
+			}
+		}
+	}
+	c.conn.Close()
+	return nil
+}
+
+// FormatCloseMessage format a close message payload
+func FormatCloseMessage(code int, reason string) []byte {
+	if code == CloseNoStatusReceived {
+		return nil
+	}
+	buf := make([]byte, 2+len(reason))
+	binary.BigEndian.PutUint16(buf, uint16(code))
+	copy(buf[2:], reason)
+	return buf
+}
+
+// IsCloseError return true if err is a *CloseError with one of the codes
+func IsCloseError(err error, codes ...int) bool {
+	var e *CloseError
+	if !errors.As(err, &e) {
+		return false
+	}
+	for _, code := range codes {
+		if e.Code == code {
+			return true
+		}
+	}
+	return false
+}
+
+// validCloseCode check the close code received, only the defined codes and
+// the ones for libraries and applications are allowed, see RFC 6455,
+// section 7.4.2
+func validCloseCode(code int) bool {
+	switch {
+	case code >= CloseNormalClosure && code <= CloseUnsupportedData:
+		return true
+	case code >= CloseInvalidPayload && code <= 1014:
+		return true
+	}
+	return code >= 3000 && code < 5000
+}
+
+func maskBytes(mask [4]byte, b []byte) {
+	for i := range b {
+		b[i] ^= mask[i&3]
+	}
+}
+
+func acceptKey(key string) string {
+	h := sha1.New()
+	h.Write([]byte(key))
+	h.Write([]byte(guid))
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+func headerContains(h http.Header, name, token string) bool {
+	for _, v := range h.Values(name) {
+		for _, t := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func sameOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(u.Host, r.Host)
+}
+
+func selectSubprotocol(r *http.Request, supported []string) string {
+	for _, s := range supported {
+		for _, v := range r.Header.Values("Sec-Websocket-Protocol") {
+			for _, p := range strings.Split(v, ",") {
+				if strings.TrimSpace(p) == s {
+					return s
+				}
+			}
+		}
+	}
+	return ""
+}
@@ -0,0 +1,194 @@
+package websocket
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newServer(t *testing.T, h func(conn *Conn)) (*httptest.Server, string) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := Upgrade(w, r, Options{ReadLimit: 1024})
+		if err != nil {
+			t.Log(err)
+			return
+		}
+		h(conn)
+	}))
+	return s, "ws" + strings.TrimPrefix(s.URL, "http")
+}
+
+func TestEcho(t *testing.T) {
+	s, url := newServer(t, func(conn *Conn) {
+		for {
+			mt, p, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			conn.WriteMessage(mt, p)
+		}
+	})
+	defer s.Close()
+
+	conn, _, err := Dial(url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	big := bytes.Repeat([]byte("x"), 300) // 16 bits length
+	for _, msg := range [][]byte{[]byte("hello"), big} {
+		if err = conn.WriteMessage(BinaryMessage, msg); err != nil {
+			t.Fatal(err)
+		}
+		mt, p, err := conn.ReadMessage()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if mt != BinaryMessage || !bytes.Equal(p, msg) {
+			t.Errorf("echo = %d %q", mt, p)
+		}
+	}
+
+	// ping is replied by pong
+	pong := make(chan string, 1)
+	conn.SetPongHandler(func(data []byte) error {
+		pong <- string(data)
+		return nil
+	})
+	conn.Ping([]byte("p"))
+	conn.WriteText("after ping")
+	if _, p, err := conn.ReadMessage(); err != nil || string(p) != "after ping" {
+		t.Fatalf("read after ping: %q %v", p, err)
+	}
+	select {
+	case data := <-pong:
+		if data != "p" {
+			t.Errorf("pong = %q", data)
+		}
+	default:
+		t.Error("no pong received")
+	}
+
+	if err = conn.Close(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestReadLimit(t *testing.T) {
+	done := make(chan error, 1)
+	s, url := newServer(t, func(conn *Conn) {
+		_, _, err := conn.ReadMessage()
+		done <- err
+	})
+	defer s.Close()
+
+	conn, _, err := Dial(url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.WriteMessage(TextMessage, bytes.Repeat([]byte("x"), 2048))
+
+	if err := <-done; err != ErrReadLimit {
+		t.Errorf("server err = %v, want ErrReadLimit", err)
+	}
+	if _, _, err := conn.ReadMessage(); !IsCloseError(err, CloseMessageTooBig) {
+		t.Errorf("client err = %v, want close 1009", err)
+	}
+}
+
+func TestReadLimitOverflow(t *testing.T) {
+	done := make(chan error, 1)
+	s, url := newServer(t, func(conn *Conn) {
+		_, _, err := conn.ReadMessage()
+		done <- err
+	})
+	defer s.Close()
+
+	conn, _, err := Dial(url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// a fragment, then a continuation frame of the 64 bits length near
+	// 2^63 which overflows the size already read
+	conn.conn.Write([]byte{0x01, 0x81, 0, 0, 0, 0, 'x'})
+	conn.conn.Write([]byte{0x00, 0x80 | 127, 0x7f, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0, 0, 0, 0})
+
+	select {
+	case err := <-done:
+		if err != ErrReadLimit {
+			t.Errorf("server err = %v, want ErrReadLimit", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("huge continuation frame not rejected")
+	}
+}
+
+func TestBadHandshake(t *testing.T) {
+	s, _ := newServer(t, func(conn *Conn) {})
+	defer s.Close()
+
+	resp, err := http.Get(s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("status = %d", resp.StatusCode)
+	}
+}
+
+func TestValidCloseCode(t *testing.T) {
+	for code, want := range map[int]bool{
+		999: false, 1000: true, 1003: true, 1004: false, 1005: false, 1006: false,
+		1007: true, 1011: true, 1014: true, 1015: false, 1016: false, 2999: false,
+		3000: true, 4999: true, 5000: false,
+	} {
+		if got := validCloseCode(code); got != want {
+			t.Errorf("validCloseCode(%d) = %v, want %v", code, got, want)
+		}
+	}
+}
+
+func TestHub(t *testing.T) {
+	hub := NewHub()
+	s, url := newServer(t, func(conn *Conn) {
+		hub.Join("room", conn)
+		defer hub.LeaveAll(conn)
+		for {
+			_, p, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			hub.Broadcast("room", TextMessage, p)
+		}
+	})
+	defer s.Close()
+
+	var conns []*Conn
+	for i := 0; i < 3; i++ {
+		conn, _, err := Dial(url, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		conns = append(conns, conn)
+	}
+	for hub.Count("room") != 3 {
+		time.Sleep(time.Millisecond)
+	}
+
+	conns[0].WriteText("hi all")
+	for i, conn := range conns {
+		if _, p, err := conn.ReadMessage(); err != nil || string(p) != "hi all" {
+			t.Errorf("conn %d read %q %v", i, p, err)
+		}
+	}
+
+	for _, conn := range conns {
+		conn.Close()
+	}
+	for hub.Count("room") != 0 {
+		time.Sleep(time.Millisecond)
+	}
+}